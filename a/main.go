@@ -41,11 +41,23 @@ var (
 	count  = flag.Bool("count", false, "count different outputs, and prints the resulting histogram")
 	digest = flag.Bool("digest", false, "compute the sha1 digest of all outputs")
 
-	// missing an outputer that takes care of "error codes"
+	failfast = flag.Bool("fail-fast", false, "stop running commands as soon as one fails")
+
+	// concurrency control (all imply '-a')
+	jobs    = flag.Int("j", 0, "maximum number of commands running at the same time (0 means no limit). Implies '-a'")
+	timeout = flag.Duration("timeout", 0, "kill commands (and their children) running longer than this duration (e.g. '30s'). Implies '-a'")
+	ordered = flag.Bool("ordered", false, "print results in path order, as soon as all the previous ones are done. Implies '-a'")
 
 	help = flag.Bool("h", false, "Print this help.")
+
+	// repository selection
+	filter cmd.FilterFlags
 )
 
+func init() {
+	filter.Flags(flag.CommandLine)
+}
+
 func usage() {
 	fmt.Printf(Usage, os.Args[0])
 	flag.PrintDefaults()
@@ -66,6 +78,7 @@ func main() {
 	}
 	//build the workspace, that is used to trigger all commands
 	workspace := sbr.NewWorkspace(wd)
+	selection := filter.FilterCmd()
 
 	// parses the remaining args in order to pass them to the underlying process
 	args := make([]string, 0)
@@ -78,7 +91,7 @@ func main() {
 		//for now there is only one way to print dependencies
 		//List just count and print all directories.
 		var count int
-		for _, prj := range workspace.ScanRel() {
+		for _, prj := range workspace.Select(selection) {
 			count++
 			rel, err := filepath.Rel(wd, prj)
 			if err != nil {
@@ -111,27 +124,38 @@ func main() {
 			xp = cmd.ExecutionPrinter
 			special = false
 		}
-		if special || *async { // this implies concurrent
+		if special || *async || *jobs > 0 || *timeout > 0 || *ordered { // this implies concurrent
 			// based on the async option, exec asynchronously or sequentially.
 			// we cannot just make "seq" a special case of concurrent, since when running sequentially we provide
 			// direct access to the std streams. commands can use stdin, and use term escape codes.
 			// When in async mode, we just can't do that.
-			executions := cmd.ExecConcurrently(workspace, name, args...)
-			xp(executions)
+			executor := cmd.NewExecutor(workspace)
+			executor.SetFailFast(*failfast)
+			executor.SetConcurrency(*jobs)
+			executor.SetTimeout(*timeout)
+			executor.SetOrdered(*ordered)
+			executor.SetFilter(selection)
+			if failed := cmd.Summarize(xp, executor.Exec(name, args...)); failed > 0 {
+				os.Exit(cmd.CodeExecFailed)
+			}
 
 		} else {
-			ExecSequentially(workspace, name, args...)
+			if failed := ExecSequentially(workspace, selection, name, args...); failed > 0 {
+				os.Exit(cmd.CodeExecFailed)
+			}
 		}
 	}
 
 }
 
-//ExecSequentially, for each `subrepository` in the working dir, execute the  command `command` with arguments `args`.
+//ExecSequentially, for each `subrepository` in the working dir matching 'f', execute the  command `command` with arguments `args`.
 // It passes the stdin, stdout, and stderr to the subprocess. and wait for the result, before moving to the next one.
-func ExecSequentially(x *sbr.Workspace, command string, args ...string) {
+//
+// It returns the number of failed commands.
+func ExecSequentially(x *sbr.Workspace, f sbr.Filter, command string, args ...string) (failed int) {
 	var count int
 
-	for _, sub := range x.ScanRel() {
+	for _, sub := range x.Select(f) {
 		count++
 
 		rel, err := filepath.Rel(x.Wd(), sub)
@@ -144,8 +168,17 @@ func ExecSequentially(x *sbr.Workspace, command string, args ...string) {
 		cmd.Dir = sub
 		cmd.Stderr, cmd.Stdout, cmd.Stdin = os.Stderr, os.Stdout, os.Stdin
 		if err := cmd.Run(); err != nil {
+			failed++
 			fmt.Printf("Error running '%s %s':\n    %s\n", command, strings.Join(args, " "), err.Error())
+			if *failfast {
+				break
+			}
 		}
 	}
-	fmt.Printf("Done (\033[00;32m%v\033[00m repositories)\n", count)
+	if failed > 0 {
+		fmt.Printf("Done (\033[00;32m%v\033[00m repositories, \033[00;31m%v\033[00m failed)\n", count, failed)
+	} else {
+		fmt.Printf("Done (\033[00;32m%v\033[00m repositories)\n", count)
+	}
+	return failed
 }
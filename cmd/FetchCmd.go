@@ -1,6 +1,7 @@
 package cmd
 
 import (
+	"flag"
 	"fmt"
 	"os"
 
@@ -8,6 +9,11 @@ import (
 )
 
 type FetchCmd struct {
+	filter FilterFlags
+}
+
+func (c *FetchCmd) Flags(fs *flag.FlagSet) {
+	c.filter.Flags(fs)
 }
 
 func (c *FetchCmd) Run(args []string) {
@@ -21,6 +27,10 @@ func (c *FetchCmd) Run(args []string) {
 	}
 	fmt.Printf("Fetching all...")
 
-	executions := ExecConcurrently(workspace, "git", "fetch")
-	ExecutionPrinter(executions)
+	executor := NewExecutor(workspace)
+	executor.SetFilter(c.filter.FilterCmd())
+	executions := executor.Exec("git", "fetch")
+	if failed := Summarize(ExecutionPrinter, executions); failed > 0 {
+		os.Exit(CodeExecFailed)
+	}
 }
@@ -0,0 +1,138 @@
+package cmd
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+
+	"github.com/ericaro/sbr/sbr"
+)
+
+type BisectCmd struct{}
+
+func (c *BisectCmd) Flags(fs *flag.FlagSet) {}
+
+func (c *BisectCmd) Run(args []string) {
+
+	workspace, err := sbr.FindWorkspace(os.Getwd())
+	if err != nil {
+		exit(CodeNoWorkingDir, "%v\n", err)
+	}
+	if len(args) == 0 {
+		exit(-1, "Usage sbr bisect start <good> <bad> | good | bad | run <command> <args> | reset\n")
+	}
+	command, args := args[0], args[1:]
+	ch := sbr.NewCheckouter(workspace, os.Stdout)
+
+	if command == "start" {
+		if len(args) != 2 {
+			exit(-1, "Usage sbr bisect start <good> <bad>: both are either a manifest file or a tag\n")
+		}
+		good, err := readManifest(workspace, args[0])
+		if err != nil {
+			exit(-1, "Cannot read %q: %v\n", args[0], err)
+		}
+		bad, err := readManifest(workspace, args[1])
+		if err != nil {
+			exit(-1, "Cannot read %q: %v\n", args[1], err)
+		}
+		changes, err := workspace.Changes(good, bad)
+		if err != nil {
+			exit(-1, "Cannot list changes: %v\n", err)
+		}
+		added, removed := sbr.Unpaired(good, bad)
+		for _, rel := range added {
+			fmt.Printf("\033[00;33mWARN\033[00m '%s' is only in %s, its changes are not bisected\n", rel, args[1])
+		}
+		for _, rel := range removed {
+			fmt.Printf("\033[00;33mWARN\033[00m '%s' is only in %s, its changes are not bisected\n", rel, args[0])
+		}
+		if len(changes) == 0 {
+			exit(-1, "No change between %s and %s\n", args[0], args[1])
+		}
+		b := sbr.NewBisect(good, changes)
+		if err := workspace.WriteBisect(b); err != nil {
+			exit(-1, "Cannot record the bisect: %v\n", err)
+		}
+		bisectStep(ch, b)
+		return
+	}
+
+	b, err := workspace.ReadBisect()
+	if err != nil {
+		exit(-1, "Cannot read the bisect: %v\n", err)
+	}
+	if b == nil {
+		exit(-1, "No bisect in progress, start it with 'sbr bisect start <good> <bad>'\n")
+	}
+
+	switch command {
+	case "good", "bad":
+		if b.Done() {
+			exit(-1, "The bisect is over, type 'sbr bisect reset' to end it\n")
+		}
+		b.Mark(command == "good")
+		if err := workspace.WriteBisect(b); err != nil {
+			exit(-1, "Cannot record the bisect: %v\n", err)
+		}
+		bisectStep(ch, b)
+
+	case "run":
+		if len(args) == 0 {
+			exit(-1, "Usage sbr bisect run <command> <args>\n")
+		}
+		for !b.Done() {
+			cmd := exec.Command(args[0], args[1:]...)
+			cmd.Dir = workspace.Wd()
+			cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+			err := cmd.Run()
+			code := 0
+			if x, ok := err.(*exec.ExitError); ok {
+				code = x.ExitCode()
+			} else if err != nil {
+				exit(-1, "Cannot run %s: %v\n", args[0], err)
+			}
+			if code == 125 { // the state cannot be tested, as in 'git bisect run'
+				exit(-1, "%s exited with 125: the state cannot be tested, bisect run stopped, mark it with 'sbr bisect good' or 'sbr bisect bad'\n", args[0])
+			}
+			if code < 0 || code >= 128 {
+				exit(-1, "%s exited with %v, bisect run stopped\n", args[0], code)
+			}
+			fmt.Printf("\n%s exited with %v: state is %s\n", args[0], code, map[bool]string{true: "good", false: "bad"}[code == 0])
+			b.Mark(code == 0)
+			if err := workspace.WriteBisect(b); err != nil {
+				exit(-1, "Cannot record the bisect: %v\n", err)
+			}
+			bisectStep(ch, b)
+		}
+
+	case "reset":
+		if err := workspace.ResetBisect(); err != nil {
+			exit(-1, "Cannot remove the bisect: %v\n", err)
+		}
+		if err := ch.CheckoutBranches(); err != nil {
+			exit(-1, "reset error: %v\n", err)
+		}
+		fmt.Println("Subrepositories are back to their branch")
+
+	default:
+		exit(-1, "Unknown bisect command %q\n", command)
+	}
+}
+
+//bisectStep checks out the next state to test, or prints the culprit.
+func bisectStep(ch *sbr.Checkouter, b *sbr.Bisect) {
+	if c, found := b.Culprit(); found {
+		fmt.Printf("\033[00;31m%s %s\033[00m is the first bad change\n", c.Rel, c.Sha)
+		fmt.Printf("    %s  %s\n", c.Date.Format("2006-01-02 15:04"), c.Subject)
+		fmt.Printf("type 'sbr bisect reset' to switch subrepositories back to their branch\n")
+		return
+	}
+	if err := ch.CheckoutManifest(b.State(b.Current)); err != nil {
+		exit(-1, "checkout error: %v\n", err)
+	}
+	c := b.Changes[b.Current-1]
+	fmt.Printf("Bisecting: %v candidate changes left (roughly %v steps)\n", b.Hi-b.Lo, b.Steps())
+	fmt.Printf("[%v/%v] %s %s %s\n", b.Current, len(b.Changes), c.Rel, short(c.Sha), c.Subject)
+}
@@ -0,0 +1,71 @@
+package cmd
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/ericaro/sbr/sbr"
+)
+
+type BranchCmd struct {
+	finish *bool
+	base   *string
+}
+
+func (c *BranchCmd) Flags(fs *flag.FlagSet) {
+	c.finish = fs.Bool("finish", false, "switch back subrepositories in the branch to their original branch")
+	c.base = fs.String("base", "", "with -finish, the branch to switch back to, when the original one is unknown (the branch was started elsewhere)")
+}
+
+func (c *BranchCmd) Run(args []string) {
+
+	workspace, err := sbr.FindWorkspace(os.Getwd())
+	if err != nil {
+		exit(CodeNoWorkingDir, "%v\n", err)
+	}
+	ch := sbr.NewCheckouter(workspace, os.Stdout)
+
+	switch {
+	case len(args) == 0: // list started branches
+		names, err := ch.Branches()
+		if err != nil {
+			exit(-1, "Cannot list branches: %v\n", err)
+		}
+		for _, name := range names {
+			fmt.Println(name)
+		}
+		return
+
+	case *c.finish:
+		if len(args) != 1 {
+			exit(-1, "Usage sbr branch -finish [-base branch] <name>\n")
+		}
+		if err := ch.FinishBranch(args[0], *c.base); err != nil {
+			exit(-1, "branch error: %v\n", err)
+		}
+
+	default:
+		if len(args) < 2 {
+			exit(-1, "Usage sbr branch <name> <path>...\n")
+		}
+		// paths are relative to the current dir
+		wd, _ := os.Getwd()
+		rels := make([]string, 0, len(args)-1)
+		for _, p := range args[1:] {
+			if !filepath.IsAbs(p) {
+				p = filepath.Join(wd, p)
+			}
+			rel, err := filepath.Rel(workspace.Wd(), p)
+			if err != nil {
+				exit(-1, "%s is not in the workspace: %v\n", p, err)
+			}
+			rels = append(rels, rel)
+		}
+		if err := ch.StartBranch(args[0], rels...); err != nil {
+			exit(-1, "branch error: %v\n", err)
+		}
+	}
+	fmt.Println("'.sbr' updated, commit it to share the branch.")
+}
@@ -0,0 +1,105 @@
+package cmd
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/ericaro/sbr/git"
+	"github.com/ericaro/sbr/sbr"
+)
+
+type ChangelogCmd struct {
+	merges *bool
+}
+
+func (c *ChangelogCmd) Flags(fs *flag.FlagSet) {
+	c.merges = fs.Bool("merges", false, "also list merge commits")
+}
+
+func (c *ChangelogCmd) Run(args []string) {
+
+	if len(args) != 2 {
+		exit(-1, "Usage sbr changelog <from> <to>: both are either a manifest file or a tag\n")
+	}
+
+	workspace, err := sbr.FindWorkspace(os.Getwd())
+	if err != nil {
+		exit(CodeNoWorkingDir, "%v\n", err)
+	}
+
+	from, err := readManifest(workspace, args[0])
+	if err != nil {
+		exit(-1, "Cannot read %q: %v\n", args[0], err)
+	}
+	to, err := readManifest(workspace, args[1])
+	if err != nil {
+		exit(-1, "Cannot read %q: %v\n", args[1], err)
+	}
+
+	ins, del, upd := sbr.Diff(from.Subs(), to.Subs())
+	sbr.Sort(ins)
+	sbr.Sort(del)
+	updated := make(map[string]sbr.Delta)
+	for _, d := range upd {
+		updated[d.Rel()] = d
+	}
+
+	gitargs := []string{"--no-merges"}
+	if *c.merges {
+		gitargs = gitargs[:0]
+	}
+
+	fmt.Printf("# Changes from %s to %s\n", args[0], args[1])
+
+	failed := 0
+	for _, p := range to { // already sorted by rel, "." first
+		old, exists := from.Rev(p.Rel())
+		if !exists {
+			continue // added
+		}
+		commits, err := git.Log(filepath.Join(workspace.Wd(), p.Rel()), append(gitargs, old+".."+p.Rev)...)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "\033[00;31mCannot read log of %s\033[00m: %v\n", p.Rel(), err)
+			failed++
+			continue
+		}
+		d, isUpdated := updated[p.Rel()]
+		if len(commits) == 0 && !isUpdated {
+			continue
+		}
+		fmt.Printf("\n## %s\n\n", p.Rel())
+		if isUpdated {
+			changes := make([]string, 0, 2)
+			if d.Old.Remote() != d.New.Remote() {
+				changes = append(changes, fmt.Sprintf("remote %s → %s", d.Old.Remote(), d.New.Remote()))
+			}
+			if d.Old.Branch() != d.New.Branch() {
+				changes = append(changes, fmt.Sprintf("branch %s → %s", d.Old.Branch(), d.New.Branch()))
+			}
+			fmt.Printf("_%s_\n\n", strings.Join(changes, ", "))
+		}
+		for _, x := range commits {
+			fmt.Printf("- %s %s (%s)\n", short(x.Sha), x.Subject, x.Author)
+		}
+	}
+
+	if len(ins) > 0 {
+		fmt.Printf("\n## Added repositories\n\n")
+		for _, s := range ins {
+			fmt.Printf("- %s (%s, %s)\n", s.Rel(), s.Remote(), s.Branch())
+		}
+	}
+	if len(del) > 0 {
+		fmt.Printf("\n## Removed repositories\n\n")
+		for _, s := range del {
+			fmt.Printf("- %s (%s, %s)\n", s.Rel(), s.Remote(), s.Branch())
+		}
+	}
+
+	if failed > 0 {
+		exit(-1, "\n%v repositories could not be read\n", failed)
+	}
+}
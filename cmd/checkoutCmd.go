@@ -10,10 +10,12 @@ import (
 )
 
 type CheckoutCmd struct {
-	prune  *bool
-	ffonly *bool
-	rebase *bool
-	dry    *bool
+	prune    *bool
+	ffonly   *bool
+	rebase   *bool
+	dry      *bool
+	at       *string
+	manifest *string
 }
 
 func (c *CheckoutCmd) Flags(fs *flag.FlagSet) {
@@ -21,6 +23,8 @@ func (c *CheckoutCmd) Flags(fs *flag.FlagSet) {
 	c.ffonly = fs.Bool("ff-only", false, "Refuse to merge and exit with a non-zero status unless the current HEAD is already up-to-date or the merge can be resolved as a fast-forward.")
 	c.rebase = fs.Bool("rebase", false, "rebase instead of merge")
 	c.dry = fs.Bool("d", false, "dry run. Only print out what would be applied")
+	c.at = fs.String("at", "", "check out every subrepository, detached, as it was at this date (e.g. '2015-03-10 18:00', 'last tuesday'), and record it")
+	c.manifest = fs.String("manifest", "", "check out every subrepository, detached, as in a manifest file, a tag, or a date recorded with -at")
 }
 
 func (c *CheckoutCmd) Run(args []string) {
@@ -30,6 +34,11 @@ func (c *CheckoutCmd) Run(args []string) {
 		exit(CodeNoWorkingDir, "%v", err)
 	}
 
+	if *c.at != "" || *c.manifest != "" {
+		c.pinned(workspace)
+		return
+	}
+
 	if *c.dry {
 
 		// compute patches
@@ -77,6 +86,33 @@ func (c *CheckoutCmd) Run(args []string) {
 
 }
 
+//pinned checks out subrepositories in detached mode, at a date, or as in a manifest.
+func (c *CheckoutCmd) pinned(workspace *sbr.Workspace) {
+	ch := sbr.NewCheckouter(workspace, os.Stdout)
+	if *c.at != "" {
+		if _, err := ch.CheckoutAt(*c.at); err != nil {
+			exit(-1, "checkout error: %v\n", err)
+		}
+		fmt.Printf("\nRecorded, check it out again with 'sbr checkout -manifest %q', or back to branches with 'sbr checkout'\n", *c.at)
+		return
+	}
+
+	spec := *c.manifest
+	if _, err := os.Stat(spec); err != nil {
+		if recorded := workspace.AtState(spec); fileExists(recorded) {
+			spec = recorded
+		}
+	}
+	m, err := readManifest(workspace, spec)
+	if err != nil {
+		exit(-1, "Cannot read manifest %s: %v\n", *c.manifest, err)
+	}
+	if err := ch.CheckoutManifest(m); err != nil {
+		exit(-1, "checkout error: %v\n", err)
+	}
+	fmt.Printf("\nBack to branches with 'sbr checkout'\n")
+}
+
 //present changes to be made to the right
 func (c *CheckoutCmd) diff(src string, target *string) (res string) {
 	if target == nil {
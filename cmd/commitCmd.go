@@ -0,0 +1,107 @@
+package cmd
+
+import (
+	"crypto/rand"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/ericaro/sbr/git"
+	"github.com/ericaro/sbr/sbr"
+)
+
+//TrailerWorkspaceChange is the commit trailer shared by all commits of a 'sbr commit'.
+const TrailerWorkspaceChange = "Workspace-Change"
+
+type CommitCmd struct {
+	message, id *string
+	all, top    *bool
+	filter      FilterFlags
+}
+
+func (c *CommitCmd) Flags(fs *flag.FlagSet) {
+	c.message = fs.String("m", "", "the commit message")
+	c.all = fs.Bool("a", false, "commit all changed tracked files, not only the ones in the index")
+	c.top = fs.Bool("top", false, "also commit in the workspace repository (after the subrepositories)")
+	c.id = fs.String("id", "", "the '"+TrailerWorkspaceChange+"' trailer value (random by default)")
+	c.filter.Flags(fs)
+}
+
+func (c *CommitCmd) Run(args []string) {
+
+	if *c.message == "" {
+		exit(-1, "Usage sbr commit -m msg [-a] [-top] [path...]\n")
+	}
+
+	workspace, err := sbr.FindWorkspace(os.Getwd())
+	if err != nil {
+		exit(CodeNoWorkingDir, "%v\n", err)
+	}
+
+	// selected subrepositories, or all of them
+	var all []string
+	if len(args) > 0 {
+		wd, _ := os.Getwd()
+		for _, p := range args {
+			if !filepath.IsAbs(p) {
+				p = filepath.Join(wd, p)
+			}
+			all = append(all, filepath.Clean(p))
+		}
+	} else {
+		all = workspace.Select(c.filter.FilterCmd())
+	}
+	// the workspace itself is always committed last, and only if asked
+	subs := make([]string, 0, len(all)+1)
+	for _, prj := range all {
+		if prj != workspace.Wd() {
+			subs = append(subs, prj)
+		}
+	}
+	if *c.top {
+		subs = append(subs, workspace.Wd())
+	}
+
+	id := *c.id
+	if id == "" {
+		id = randomID()
+	}
+	trailer := fmt.Sprintf("%s: %s", TrailerWorkspaceChange, id)
+
+	w := tabwriter.NewWriter(os.Stdout, 4, 8, 2, ' ', 0)
+	committed, failed := 0, 0
+	for _, prj := range subs {
+		rel := sbr.NewRepository(workspace.Wd(), prj).Rel
+		changes, err := git.HasChanges(prj, *c.all)
+		if err == nil && !changes {
+			continue // nothing to commit
+		}
+		if err == nil {
+			err = git.CommitChanges(prj, *c.message, *c.all, trailer)
+		}
+		if err != nil {
+			failed++
+			fmt.Fprintf(w, "\033[00;31mFAILED   \033[00m\t%s\t%s\n", rel, strings.Replace(strings.TrimSpace(err.Error()), "\n", "; ", -1))
+			continue
+		}
+		committed++
+		sha, _ := git.RevParseHead(prj)
+		fmt.Fprintf(w, "\033[00;32mCOMMITTED\033[00m\t%s\t%s\n", rel, short(sha))
+	}
+	w.Flush()
+
+	fmt.Printf("\n%v commits (%s: %s)\n", committed, TrailerWorkspaceChange, id)
+	if failed > 0 {
+		exit(CodeExecFailed, "%v repositories could not be committed\n", failed)
+	}
+}
+
+//randomID returns a short random hexadecimal identifier.
+func randomID() string {
+	b := make([]byte, 6)
+	rand.Read(b)
+	return fmt.Sprintf("%x", b)
+}
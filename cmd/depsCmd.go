@@ -0,0 +1,58 @@
+package cmd
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/ericaro/sbr/sbr"
+)
+
+type DepsCmd struct {
+	dot   *bool
+	graph *bool
+}
+
+func (c *DepsCmd) Flags(fs *flag.FlagSet) {
+	c.dot = fs.Bool("dot", false, "only print the dependency graph in graphviz's DOT format")
+	c.graph = fs.Bool("graph", false, "also print the dependency graph between subrepositories")
+}
+
+func (c *DepsCmd) Run(args []string) {
+
+	workspace, err := sbr.FindWorkspace(os.Getwd())
+	if err != nil {
+		exit(CodeNoWorkingDir, "%v", err)
+	}
+
+	deps, err := workspace.Dependencies()
+	if err != nil {
+		exit(-1, "Cannot compute dependencies: %v\n", err)
+	}
+
+	if *c.dot {
+		deps.WriteDot(os.Stdout)
+		return
+	}
+
+	missing := deps.MissingImports()
+	w := tabwriter.NewWriter(os.Stdout, 3, 8, 3, ' ', 0)
+	for _, imp := range missing {
+		fmt.Fprintf(w, "\033[00;31mMISSING\033[00m\t%s\t%s\t\n", imp, strings.Join(deps.Missing[imp], ", "))
+	}
+	for _, rel := range deps.Unused() {
+		fmt.Fprintf(w, "\033[00;34mUNUSED \033[00m\t%s\t\t\n", rel)
+	}
+	w.Flush()
+
+	if *c.graph {
+		fmt.Println()
+		deps.WriteGraph(os.Stdout)
+	}
+
+	if len(missing) > 0 {
+		exit(CodeMissingImports, "\n%v imports are not satisfied by any subrepository\n", len(missing))
+	}
+}
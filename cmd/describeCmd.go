@@ -0,0 +1,34 @@
+package cmd
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/ericaro/sbr/sbr"
+)
+
+type DescribeCmd struct {
+	always *bool
+}
+
+func (c *DescribeCmd) Flags(fs *flag.FlagSet) {
+	c.always = fs.Bool("always", false, "print the short workspace version when no tag is common to all subrepositories, instead of failing")
+}
+
+func (c *DescribeCmd) Run(args []string) {
+
+	workspace, err := sbr.FindWorkspace(os.Getwd())
+	if err != nil {
+		exit(CodeNoWorkingDir, "%v\n", err)
+	}
+
+	d, err := workspace.Describe()
+	if err != nil {
+		exit(-1, "Cannot describe the workspace: %v\n", err)
+	}
+	if d.Tag == "" && !*c.always {
+		exit(-1, "No tag is common to the workspace and all subrepositories, use -always to print the workspace version anyway\n")
+	}
+	fmt.Println(d)
+}
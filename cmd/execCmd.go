@@ -2,20 +2,21 @@ package cmd
 
 import (
 	"crypto/sha1"
+	"encoding/json"
 	"flag"
 	"fmt"
+	"io"
 	"log"
 	"math"
 	"os"
-	"os/exec"
-	"path/filepath"
 	"sort"
 	"strconv"
 	"strings"
 	"sync"
 	"text/tabwriter"
+	"text/template"
+	"time"
 
-	"github.com/ericaro/sbr/git"
 	"github.com/ericaro/sbr/sbr"
 )
 
@@ -27,23 +28,68 @@ type ExecutionProcessor func(<-chan Execution)
 //Execution is the result of a command Execution on a given project
 // You get the project's name (the full path to the repository )
 type Execution struct {
-	Name   string
-	Rel    string // relative path to the root
-	Cmd    string
-	Args   []string
-	Result string
+	Name     string
+	Rel      string // relative path to the root
+	Cmd      string
+	Args     []string
+	Result   string        // combined stdout and stderr
+	Stderr   string        // stderr only
+	Code     int           // exit code, -1 if the command could not be run at all
+	Duration time.Duration // time spent running the command
+	Err      error         // nil if the command succeeded
+}
+
+//Failed returns true if the command did not succeed.
+func (x Execution) Failed() bool { return x.Err != nil }
+
+//Status returns a short description of the execution status: "ok", "exit <code>", or the error.
+func (x Execution) Status() string {
+	switch {
+	case x.Err == nil:
+		return "ok"
+	case x.Code > 0:
+		return fmt.Sprintf("exit %d", x.Code)
+	default:
+		return x.Err.Error()
+	}
 }
 
 //ExecutionPrinter just print a colored header and the result
 func ExecutionPrinter(source <-chan Execution) {
-	var count int
+	var count, failed int
 	for x := range source {
 		count++
 		// default printing
-		fmt.Printf("\033[00;32m%s\033[00m$ %s %s \n%s\n", x.Rel, x.Cmd, strings.Join(x.Args, " "), x.Result)
+		if x.Failed() {
+			failed++
+			fmt.Printf("\033[00;31m%s\033[00m$ %s %s (%s)\n%s\n", x.Rel, x.Cmd, strings.Join(x.Args, " "), x.Status(), x.Result)
+		} else {
+			fmt.Printf("\033[00;32m%s\033[00m$ %s %s \n%s\n", x.Rel, x.Cmd, strings.Join(x.Args, " "), x.Result)
+		}
+	}
+
+	printDone(count, failed)
+}
+
+//ExecutionStreamed only prints the number of repositories: outputs have been streamed by a Streamer.
+func ExecutionStreamed(source <-chan Execution) {
+	var count, failed int
+	for x := range source {
+		count++
+		if x.Failed() {
+			failed++
+		}
 	}
+	printDone(count, failed)
+}
 
-	fmt.Printf("Done (\033[00;32m%v\033[00m repositories)\n", count)
+//printDone prints the final count of repositories.
+func printDone(count, failed int) {
+	if failed > 0 {
+		fmt.Printf("Done (\033[00;32m%v\033[00m repositories, \033[00;31m%v\033[00m failed)\n", count, failed)
+	} else {
+		fmt.Printf("Done (\033[00;32m%v\033[00m repositories)\n", count)
+	}
 }
 
 //ExecutionCat ExecutionProcessor `cat` together all outputs.
@@ -55,6 +101,8 @@ func ExecutionCat(source <-chan Execution) {
 
 //ExecutionSum  attempt to parse the Execution result as a number and sum it up.
 // if it can parse it as a number it uses `NaN`.
+//
+// failed executions are printed but not summed up.
 func ExecutionSum(source <-chan Execution) {
 	var total float64
 	w := tabwriter.NewWriter(os.Stdout, 6, 8, 3, '\t', 0)
@@ -65,6 +113,10 @@ func ExecutionSum(source <-chan Execution) {
 		if err != nil {
 			res = math.NaN()
 		}
+		if x.Failed() {
+			fmt.Fprintf(w, "\t%v\t%s\t%s\n", res, x.Rel, x.Status())
+			continue
+		}
 		fmt.Fprintf(w, "\t%v\t%s\n", res, x.Rel)
 		total += res
 	}
@@ -76,12 +128,17 @@ func ExecutionSum(source <-chan Execution) {
 }
 
 //ExecutionCount counts different outputs
+//
+// failed executions are counted apart, prefixed by their status.
 func ExecutionCount(source <-chan Execution) {
 	hist := make(map[string]int)
 	var count int
 	for x := range source {
 		count++
 		cleaned := strings.Trim(x.Result, " \n\r\t")
+		if x.Failed() {
+			cleaned = fmt.Sprintf("(%s) %s", x.Status(), cleaned)
+		}
 		hist[cleaned]++
 	}
 	w := tabwriter.NewWriter(os.Stdout, 0, 8, 3, '\t', 0)
@@ -98,6 +155,8 @@ func ExecutionCount(source <-chan Execution) {
 
 //ExecutionDigest computes the digest of all execution results concatenated.
 // Outputs are trimed of whitespaces. (` \n\r\t`)
+//
+// the status of failed executions is part of the digest.
 func ExecutionDigest(source <-chan Execution) {
 
 	//we are going to sort prj by name first
@@ -113,11 +172,73 @@ func ExecutionDigest(source <-chan Execution) {
 	// now compute the sha1
 	h := sha1.New()
 	for _, x := range all {
+		if x.Failed() {
+			fmt.Fprint(h, x.Status())
+		}
 		fmt.Fprint(h, x.Result)
 	}
 	fmt.Printf("%x\n", h.Sum(nil))
 }
 
+//ExecutionFormatter returns an ExecutionProcessor that renders each execution through 'tmpl', followed by a new line.
+func ExecutionFormatter(tmpl *template.Template) ExecutionProcessor {
+	return func(source <-chan Execution) {
+		for x := range source {
+			if err := tmpl.Execute(os.Stdout, x); err != nil {
+				fmt.Fprintf(os.Stderr, "%s: cannot format: %v\n", x.Rel, err)
+			}
+			fmt.Println()
+		}
+	}
+}
+
+//ExecutionJSON prints one JSON object per execution, per line.
+func ExecutionJSON(source <-chan Execution) {
+	enc := json.NewEncoder(os.Stdout)
+	for x := range source {
+		var errmess string
+		if x.Err != nil {
+			errmess = x.Err.Error()
+		}
+		enc.Encode(struct {
+			Name     string   `json:"name"`
+			Rel      string   `json:"rel"`
+			Cmd      string   `json:"cmd"`
+			Args     []string `json:"args"`
+			Result   string   `json:"result"`
+			Stderr   string   `json:"stderr"`
+			Code     int      `json:"code"`
+			Duration float64  `json:"duration"` // in seconds
+			Error    string   `json:"error,omitempty"`
+		}{x.Name, x.Rel, x.Cmd, x.Args, x.Result, x.Stderr, x.Code, x.Duration.Seconds(), errmess})
+	}
+}
+
+//ExecutionTee returns an ExecutionProcessor that feeds every execution to all the processors 'xps', concurrently.
+func ExecutionTee(xps ...ExecutionProcessor) ExecutionProcessor {
+	return func(source <-chan Execution) {
+		sinks := make([]chan Execution, len(xps))
+		var waiter sync.WaitGroup
+		for i, xp := range xps {
+			sinks[i] = make(chan Execution)
+			waiter.Add(1)
+			go func(xp ExecutionProcessor, sink <-chan Execution) {
+				defer waiter.Done()
+				xp(sink)
+			}(xp, sinks[i])
+		}
+		for x := range source {
+			for _, sink := range sinks {
+				sink <- x
+			}
+		}
+		for _, sink := range sinks {
+			close(sink)
+		}
+		waiter.Wait()
+	}
+}
+
 //byExecName to sort any slice of Execution by their Name !
 type byExecName []Execution
 
@@ -125,9 +246,76 @@ func (a byExecName) Len() int           { return len(a) }
 func (a byExecName) Swap(i, j int)      { a[i], a[j] = a[j], a[i] }
 func (a byExecName) Less(i, j int) bool { return a[i].Name < a[j].Name }
 
+//ExecutionSummary keeps track of the executions flowing through Watch, to report failures.
+type ExecutionSummary struct {
+	count  int
+	failed []Execution
+}
+
+//Watch returns a chan that delivers all executions from 'source', recording them on the way.
+func (s *ExecutionSummary) Watch(source <-chan Execution) <-chan Execution {
+	watched := make(chan Execution)
+	go func() {
+		defer close(watched)
+		for x := range source {
+			s.count++
+			if x.Failed() {
+				s.failed = append(s.failed, x)
+			}
+			watched <- x
+		}
+	}()
+	return watched
+}
+
+//Failed returns the failed executions, sorted by Name.
+func (s *ExecutionSummary) Failed() []Execution {
+	sort.Sort(byExecName(s.failed))
+	return s.failed
+}
+
+//Print writes out the list of failed executions, if any.
+func (s *ExecutionSummary) Print(w io.Writer) {
+	failed := s.Failed()
+	if len(failed) == 0 {
+		return
+	}
+	fmt.Fprintf(w, "\n\033[00;31m%v\033[00m/%v repositories failed:\n", len(failed), s.count)
+	tw := tabwriter.NewWriter(w, 4, 8, 2, ' ', 0)
+	for _, x := range failed {
+		fmt.Fprintf(tw, "    %s\t%s\t%s\t%s\n", x.Rel, x.Status(), x.Duration, firstLine(x.Stderr))
+	}
+	tw.Flush()
+}
+
+//Summarize process executions with 'xp', and then print out failures on stderr.
+//
+// It returns the number of failed executions.
+func Summarize(xp ExecutionProcessor, executions <-chan Execution) int {
+	summary := new(ExecutionSummary)
+	xp(summary.Watch(executions))
+	summary.Print(os.Stderr)
+	return len(summary.Failed())
+}
+
+//firstLine returns the first line of s.
+func firstLine(s string) string {
+	if i := strings.Index(s, "\n"); i >= 0 {
+		return s[:i]
+	}
+	return s
+}
+
 type ExecCmd struct {
 	cat, sum, count, digest *bool
 	local                   *bool
+	failfast, ordered, topo *bool
+	templated, json, stream *bool
+	format                  *string
+	reports                 Reports
+	jobs                    *int
+	timeout                 *time.Duration
+	filter                  FilterFlags
 }
 
 func (c *ExecCmd) Flags(fs *flag.FlagSet) {
@@ -136,7 +324,18 @@ func (c *ExecCmd) Flags(fs *flag.FlagSet) {
 	c.sum = fs.Bool("sum", false, "parse each output as a number and print out the total")
 	c.count = fs.Bool("count", false, "count different outputs, and prints the resulting histogram")
 	c.digest = fs.Bool("digest", false, "compute the sha1 digest of all outputs")
+	c.format = fs.String("format", "", "print each execution using this text/template (e.g. '{{.Rel}}: {{.Status}}'). Fields are Name, Rel, Cmd, Args, Result, Stderr, Code, Duration")
+	c.json = fs.Bool("json", false, "print one JSON object per execution")
+	c.stream = fs.Bool("stream", false, "print output lines as they arrive, prefixed by the repository")
+	fs.Var(&c.reports, "report", "also write a report '<format>:<file>', format is 'junit' or 'tap', file '-' is stdout. Can be repeated")
+	c.templated = fs.Bool("t", false, "expand arguments as text/template for each repository (e.g. '{{.Rel}}'). Fields are Name, Rel, Path, Remote, Branch")
 	c.local = fs.Bool("l", false, "start in the current working dir. Default is to start in the sbr workspace")
+	c.failfast = fs.Bool("fail-fast", false, "cancel remaining commands as soon as one fails")
+	c.jobs = fs.Int("j", 0, "maximum number of commands running at the same time (0 means no limit)")
+	c.timeout = fs.Duration("timeout", 0, "kill commands (and their children) running longer than this duration (e.g. '30s')")
+	c.ordered = fs.Bool("ordered", false, "print results in path order, as soon as all the previous ones are done")
+	c.topo = fs.Bool("topo", false, "run in dependency order (from go imports, see 'sbr deps'), with maximum parallelism for each level")
+	c.filter.Flags(fs)
 
 }
 
@@ -167,50 +366,77 @@ func (c *ExecCmd) Run(args []string) {
 		xargs = args[1:]
 	}
 	name := args[0]
-	executions := ExecConcurrently(workspace, name, xargs...)
+
+	if *c.templated {
+		if _, err := ParseArgsTemplate(xargs); err != nil {
+			exit(-1, "Invalid argument template: %v\n", err)
+		}
+	}
+
+	var xp ExecutionProcessor
 	switch {
+	case *c.format != "":
+		tmpl, err := template.New("format").Parse(*c.format)
+		if err != nil {
+			exit(-1, "Invalid format: %v\n", err)
+		}
+		xp = ExecutionFormatter(tmpl)
+	case *c.json:
+		xp = ExecutionJSON
 	case *c.cat:
-		ExecutionCat(executions)
+		xp = ExecutionCat
 	case *c.sum:
-		ExecutionSum(executions)
+		xp = ExecutionSum
 	case *c.count:
-		ExecutionCount(executions)
+		xp = ExecutionCount
 	case *c.digest:
-		ExecutionDigest(executions)
+		xp = ExecutionDigest
+	case *c.stream:
+		xp = ExecutionStreamed
 	default:
-		ExecutionPrinter(executions)
+		xp = ExecutionPrinter
 	}
-}
 
-//ExecConcurently, for each `subrepository` in the working dir, execute the command `command` with arguments `args`.
-// Each command is executed in non interactive mode (no access to stdin/stdout)
-func ExecConcurrently(x *sbr.Workspace, command string, args ...string) <-chan Execution {
-	executions := make(chan Execution)
-	var waiter sync.WaitGroup // to wait for all commands to return
-	for _, sub := range x.ScanRel() {
-		waiter.Add(1)
-
-		go func(sub string) {
-			defer waiter.Done()
-			cmd := exec.Command(command, args...)
-			cmd.Dir = sub
-			out, err := cmd.CombinedOutput()
-			if err != nil {
-				return
-			}
-			rel := filepath.Join(x.Wd(), sub)
-			// keep
-			//head := fmt.Sprintf("\033[00;32m%s\033[00m$ %s %s\n", sub, command, strings.Join(args, " "))
-			//executions <- head + string(out)
-			result := string(out)
-			result = strings.Trim(result, git.DefaultTrimCut)
-			executions <- Execution{Name: sub, Rel: rel, Cmd: command, Args: args, Result: result}
-		}(sub)
+	reports, closers, err := c.reports.Open()
+	if err != nil {
+		exit(-1, "Cannot create report: %v\n", err)
+	}
+	if len(reports) > 0 {
+		xp = ExecutionTee(append([]ExecutionProcessor{xp}, reports...)...)
 	}
 
-	go func() {
-		waiter.Wait()
-		close(executions)
-	}()
-	return executions
+	executor := NewExecutor(workspace)
+	if *c.topo {
+		deps, err := workspace.Dependencies()
+		if err != nil {
+			exit(-1, "Cannot compute dependencies: %v\n", err)
+		}
+		if _, err := deps.Levels(); err != nil {
+			exit(-1, "Cannot run in dependency order: %v\n", err)
+		}
+		executor.SetDependencies(deps)
+	}
+	executor.SetFailFast(*c.failfast)
+	executor.SetConcurrency(*c.jobs)
+	executor.SetTimeout(*c.timeout)
+	executor.SetOrdered(*c.ordered)
+	executor.SetFilter(c.filter.FilterCmd())
+	executor.SetTemplated(*c.templated)
+	if *c.stream {
+		executor.SetStreamer(NewStreamer(os.Stdout))
+	}
+	failed := Summarize(xp, executor.Exec(name, xargs...))
+	reportFailed := false
+	for _, f := range closers {
+		if err := f.Close(); err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			reportFailed = true
+		}
+	}
+	if reportFailed {
+		exit(CodeExecFailed, "Reports are incomplete\n")
+	}
+	if failed > 0 {
+		os.Exit(CodeExecFailed)
+	}
 }
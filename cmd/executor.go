@@ -0,0 +1,373 @@
+package cmd
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ericaro/sbr/git"
+	"github.com/ericaro/sbr/sbr"
+)
+
+var (
+	ErrCancelled = errors.New("cancelled")
+	ErrTimeout   = errors.New("timeout")
+)
+
+//ErrDependency is the error of commands not run because a dependency failed.
+type ErrDependency string
+
+func (e ErrDependency) Error() string { return fmt.Sprintf("skipped: dependency %s failed", string(e)) }
+
+//Executor runs a command in every subrepository of a workspace, and collects the results as Execution.
+type Executor struct {
+	wk          *sbr.Workspace
+	failfast    bool
+	concurrency int               // max number of commands running at once, 0 means no limit
+	timeout     time.Duration     // max duration of each command, 0 means no limit
+	ordered     bool              // deliver executions in execution order
+	filter      sbr.Filter        // select subrepositories, nil means all
+	templated   bool              // arguments are templates expanded for each subrepository
+	deps        *sbr.Dependencies // run in dependency order, nil means no order
+	stream      *Streamer         // also stream outputs line by line, nil means no stream
+
+	failed map[string]bool // rel of failed commands (in dependency order)
+	mu     sync.Mutex
+
+	cancel chan struct{} // closed to cancel remaining commands
+	once   sync.Once
+}
+
+//NewExecutor creates an Executor for the workspace.
+func NewExecutor(workspace *sbr.Workspace) *Executor {
+	return &Executor{
+		wk:     workspace,
+		cancel: make(chan struct{}),
+	}
+}
+
+//SetFailFast cancels all remaining commands as soon as one fails.
+func (e *Executor) SetFailFast(failfast bool) { e.failfast = failfast }
+
+//SetConcurrency limits the number of commands running at the same time (0 means no limit).
+func (e *Executor) SetConcurrency(n int) { e.concurrency = n }
+
+//SetTimeout kills commands (and their children) that run longer than d (0 means no limit).
+func (e *Executor) SetTimeout(d time.Duration) { e.timeout = d }
+
+//SetOrdered delivers executions in execution order (path order, unless dependencies are set), each one as soon as
+// all its predecessors have been delivered.
+func (e *Executor) SetOrdered(ordered bool) { e.ordered = ordered }
+
+//SetFilter only runs commands in subrepositories matching f (nil means all).
+func (e *Executor) SetFilter(f sbr.Filter) { e.filter = f }
+
+//SetTemplated expands arguments as text/template for each subrepository (see Target).
+func (e *Executor) SetTemplated(templated bool) { e.templated = templated }
+
+//SetDependencies runs commands in dependency order (see Dependencies.Levels): a command only starts when
+// the commands in all the subrepositories it depends on are done. Commands in subrepositories whose
+// dependencies failed are skipped (reported as failed with ErrDependency).
+//
+// Subrepositories not in 'd' are run last. 'd' must not contain cycles.
+func (e *Executor) SetDependencies(d *sbr.Dependencies) { e.deps = d }
+
+//SetStreamer streams every output line to 's' as it arrives.
+func (e *Executor) SetStreamer(s *Streamer) { e.stream = s }
+
+//Cancel kills running commands, they are reported as failed with ErrCancelled.
+func (e *Executor) Cancel() { e.once.Do(func() { close(e.cancel) }) }
+
+//Exec, for each `subrepository` in the working dir, execute the command `command` with arguments `args`.
+// Each command is executed concurrently in non interactive mode (no access to stdin/stdout).
+//
+// Every command produces exactly one Execution, failed or not. An interrupt signal cancels all commands.
+func (e *Executor) Exec(command string, args ...string) <-chan Execution {
+	batches := e.batches(e.wk.Select(e.filter))
+	subs := make([]string, 0, 100) // all subs in execution order
+	for _, batch := range batches {
+		subs = append(subs, batch...)
+	}
+	expand := e.expander(args)
+	e.failed = make(map[string]bool)
+	if e.stream != nil {
+		for _, sub := range subs {
+			e.stream.Register(e.rel(sub))
+		}
+	}
+
+	// one result chan per sub, so that they can be delivered in order
+	results := make([]chan Execution, len(subs))
+	for i := range results {
+		results[i] = make(chan Execution, 1)
+	}
+
+	interrupts := make(chan os.Signal, 1)
+	signal.Notify(interrupts, os.Interrupt)
+	go func() {
+		if _, ok := <-interrupts; ok {
+			e.Cancel()
+		}
+	}()
+
+	// dispatch commands in order, batch after batch, limiting the concurrency
+	var sem chan struct{}
+	if e.concurrency > 0 {
+		sem = make(chan struct{}, e.concurrency)
+	}
+	go func() {
+		i := 0
+		for _, batch := range batches {
+			var waiter sync.WaitGroup // to wait for the whole batch
+			for _, sub := range batch {
+				acquired := false
+				if sem != nil {
+					select {
+					case sem <- struct{}{}:
+						acquired = true
+					case <-e.cancel: // run will report it as cancelled without starting it
+					}
+				}
+				waiter.Add(1)
+				go func(sub string, result chan<- Execution, acquired bool) {
+					defer waiter.Done()
+					var x Execution
+					if dep, blocked := e.blocked(sub); blocked {
+						x = e.abort(sub, command, args, ErrDependency(dep))
+					} else if xargs, err := expand(sub); err != nil {
+						x = e.abort(sub, command, args, err)
+					} else {
+						x = e.run(sub, command, xargs...)
+					}
+					if acquired {
+						<-sem
+					}
+					if x.Failed() {
+						e.fail(x.Rel)
+						if e.failfast {
+							e.Cancel()
+						}
+					}
+					result <- x
+				}(sub, results[i], acquired)
+				i++
+			}
+			waiter.Wait()
+		}
+	}()
+
+	executions := make(chan Execution)
+	go func() {
+		defer func() {
+			signal.Stop(interrupts)
+			close(interrupts)
+			close(executions)
+		}()
+		if e.ordered {
+			for _, result := range results {
+				executions <- <-result
+			}
+			return
+		}
+		// merge results as they come
+		merged := make(chan Execution)
+		for _, result := range results {
+			go func(result <-chan Execution) { merged <- <-result }(result)
+		}
+		for _ = range results {
+			executions <- <-merged
+		}
+	}()
+	return executions
+}
+
+//expander returns the function that computes the arguments for each subrepository.
+func (e *Executor) expander(args []string) func(sub string) ([]string, error) {
+	if !e.templated {
+		return func(sub string) ([]string, error) { return args, nil }
+	}
+	tmpl, err := ParseArgsTemplate(args)
+	if err != nil {
+		return func(sub string) ([]string, error) { return nil, err }
+	}
+	declared := make(map[string]sbr.Sub)
+	subs, _ := e.wk.Read() // undeclared subrepositories get their values from git
+	for _, s := range subs {
+		declared[s.Rel()] = s
+	}
+	return func(sub string) ([]string, error) {
+		return tmpl.Expand(NewTarget(e.wk.Wd(), sub, declared))
+	}
+}
+
+//batches groups subs in batches to be run one after the other.
+//
+// there is only one batch, unless dependencies are set.
+func (e *Executor) batches(subs []string) [][]string {
+	if e.deps == nil {
+		return [][]string{subs}
+	}
+	levels, _ := e.deps.Levels()                // no cycles, it has been checked before
+	index := make(map[string]string, len(subs)) // rel -> sub
+	for _, sub := range subs {
+		index[e.rel(sub)] = sub
+	}
+	batches := make([][]string, 0, len(levels)+1)
+	for _, level := range levels {
+		batch := make([]string, 0, len(level))
+		for _, rel := range level {
+			if sub, exists := index[rel]; exists {
+				batch = append(batch, sub)
+				delete(index, rel)
+			}
+		}
+		batches = append(batches, batch)
+	}
+	// remaining subs are not in the dependencies
+	last := make([]string, 0, len(index))
+	for _, sub := range subs {
+		if _, exists := index[e.rel(sub)]; exists {
+			last = append(last, sub)
+		}
+	}
+	return append(batches, last)
+}
+
+//blocked returns a failed dependency of 'sub', if any.
+func (e *Executor) blocked(sub string) (dep string, blocked bool) {
+	if e.deps == nil {
+		return
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	for _, dep := range e.deps.Graph[e.rel(sub)] {
+		if e.failed[dep] {
+			return dep, true
+		}
+	}
+	return
+}
+
+//fail records a failed command.
+func (e *Executor) fail(rel string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.failed[rel] = true
+}
+
+//abort creates the Execution of a command that could not be run.
+func (e *Executor) abort(sub, command string, args []string, err error) Execution {
+	x := e.execution(sub, command, args)
+	x.Code, x.Err = -1, err
+	return x
+}
+
+//execution creates an Execution ready to run.
+func (e *Executor) execution(sub, command string, args []string) Execution {
+	return Execution{Name: sub, Rel: e.rel(sub), Cmd: command, Args: args}
+}
+
+//rel returns the path of 'sub' relative to the workspace.
+func (e *Executor) rel(sub string) string {
+	rel, err := filepath.Rel(e.wk.Wd(), sub)
+	if err != nil {
+		return sub // rel is only use for presentation
+	}
+	return rel
+}
+
+//run executes the command in the 'sub' directory.
+func (e *Executor) run(sub, command string, args ...string) Execution {
+	x := e.execution(sub, command, args)
+
+	select {
+	case <-e.cancel: // do not even start
+		x.Code, x.Err = -1, ErrCancelled
+		return x
+	default:
+	}
+
+	combined := new(lockedBuffer)
+	stderr := new(bytes.Buffer)
+	cmd := exec.Command(command, args...)
+	cmd.Dir = sub
+	cmd.Stdout = combined
+	cmd.Stderr = io.MultiWriter(combined, stderr)
+	if e.stream != nil {
+		out, errout := e.stream.Writer(x.Rel, false), e.stream.Writer(x.Rel, true)
+		defer out.Close()
+		defer errout.Close()
+		cmd.Stdout = io.MultiWriter(combined, out)
+		cmd.Stderr = io.MultiWriter(combined, stderr, errout)
+	}
+	setProcessGroup(cmd)
+
+	var timeout <-chan time.Time
+	if e.timeout > 0 {
+		timer := time.NewTimer(e.timeout)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+
+	start := time.Now()
+	err := cmd.Start()
+	if err == nil {
+		wait := make(chan error, 1)
+		go func() { wait <- cmd.Wait() }()
+		select {
+		case err = <-wait:
+		case <-e.cancel:
+			killProcessGroup(cmd)
+			<-wait
+			err = ErrCancelled
+		case <-timeout:
+			killProcessGroup(cmd)
+			<-wait
+			err = ErrTimeout
+		}
+	}
+	x.Duration = time.Since(start)
+	x.Result = strings.Trim(combined.String(), git.DefaultTrimCut)
+	x.Stderr = strings.Trim(stderr.String(), git.DefaultTrimCut)
+
+	switch err := err.(type) {
+	case nil:
+	case *exec.ExitError:
+		x.Code, x.Err = err.ExitCode(), err
+	default:
+		x.Code, x.Err = -1, err
+	}
+	return x
+}
+
+//ExecConcurently, for each `subrepository` in the working dir, execute the command `command` with arguments `args`.
+// Each command is executed in non interactive mode (no access to stdin/stdout)
+func ExecConcurrently(x *sbr.Workspace, command string, args ...string) <-chan Execution {
+	return NewExecutor(x).Exec(command, args...)
+}
+
+//lockedBuffer is a bytes.Buffer safe for concurrent writes (stdout and stderr are copied concurrently).
+type lockedBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *lockedBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *lockedBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
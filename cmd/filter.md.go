@@ -0,0 +1,48 @@
+package cmd
+
+var FilterMd = `
+# Filter
+
+'sbr x', 'sbr status', 'sbr fetch' and 'a' can be restricted to a selection of
+repositories, using two options:
+
+  - '-path <pattern>': the repository path, relative to the workspace, must match the pattern.
+  - '-where <expression>': the repository must match the expression.
+
+When both are used, repositories must match both.
+
+## Patterns
+
+Patterns are shell-like patterns: '*' matches any sequence of characters but '/',
+'?' matches any single character but '/', and '[a-z]' matches a character range.
+
+    $ sbr x -path 'src/github.com/acme/*' git log -1
+
+## Expressions
+
+An expression combines conditions with '&&' (and), '||' (or), '!' (not), and parenthesis.
+
+A condition is an attribute of the repository, optionally compared to a value.
+
+Numeric attributes are compared with '==', '!=', '<', '<=', '>', '>=' to a number,
+alone they are true if they are not zero:
+
+  - 'dirty': the number of changed files (as in 'git status --porcelain')
+  - 'ahead': the number of commits to be pushed to the upstream
+  - 'behind': the number of commits to be pulled from the upstream
+
+'clean' is true when there is no changed file.
+
+Textual attributes are compared with '==' or '!=' to a pattern (that can be quoted):
+
+  - 'branch': the current branch
+  - 'remote': the 'origin' remote url
+  - 'path': the path relative to the workspace
+
+A condition that cannot be evaluated (for instance 'ahead' on a branch without upstream) is false.
+
+    $ sbr x -where 'dirty && branch!=master' git status -s
+    $ sbr status -where '(ahead || behind>2) && path==src/github.com/acme/*'
+    $ sbr fetch -where 'branch=="feature/*"'
+
+`
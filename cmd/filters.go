@@ -0,0 +1,48 @@
+package cmd
+
+import (
+	"flag"
+
+	"github.com/ericaro/sbr/sbr"
+)
+
+//FilterFlags declares the flags to select subrepositories, and build the matching sbr.Filter.
+type FilterFlags struct {
+	where, path *string
+}
+
+//Flags declares '-where' and '-path' flags into fs.
+func (c *FilterFlags) Flags(fs *flag.FlagSet) {
+	c.where = fs.String("where", "", "only select repositories matching this expression (e.g. 'dirty && branch!=master'). See 'sbr help filter'")
+	c.path = fs.String("path", "", "only select repositories whose relative path matches this pattern (e.g. 'src/github.com/acme/*')")
+}
+
+//Filter returns the filter described by the flags, nil if there is none.
+func (c *FilterFlags) Filter() (f sbr.Filter, err error) {
+	var where, path sbr.Filter
+	if *c.where != "" {
+		where, err = sbr.ParseFilter(*c.where)
+		if err != nil {
+			return
+		}
+	}
+	if *c.path != "" {
+		path, err = sbr.PathFilter(*c.path)
+		if err != nil {
+			return
+		}
+	}
+	if where == nil && path == nil {
+		return nil, nil
+	}
+	return sbr.And(where, path), nil
+}
+
+//FilterCmd returns the filter described by the flags, or exit.
+func (c *FilterFlags) FilterCmd() sbr.Filter {
+	f, err := c.Filter()
+	if err != nil {
+		exit(CodeInvalidFilter, "%v\n", err)
+	}
+	return f
+}
@@ -10,6 +10,11 @@ const (
 	CodeMissingBranch       = -6
 	CodeMissingRemoteOrigin = -7
 	CodeCannotAddJob        = -8
+	CodeMissingImports      = -9
+	CodeExecFailed          = -10
+	CodeInvalidFilter       = -11
+	CodePushBlocked         = -12
+	CodeUncommitted         = -13
 )
 
 var (
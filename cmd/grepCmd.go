@@ -0,0 +1,102 @@
+package cmd
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/ericaro/sbr/git"
+	"github.com/ericaro/sbr/sbr"
+)
+
+type GrepCmd struct {
+	files, count, ignoreCase, word *bool
+	filter                         FilterFlags
+}
+
+func (c *GrepCmd) Flags(fs *flag.FlagSet) {
+	c.files = fs.Bool("l", false, "only print the name of files that match")
+	c.count = fs.Bool("c", false, "only print the number of matching lines per file")
+	c.ignoreCase = fs.Bool("i", false, "ignore case differences")
+	c.word = fs.Bool("w", false, "match the pattern only at word boundary")
+	c.filter.Flags(fs)
+}
+
+func (c *GrepCmd) Run(args []string) {
+
+	if len(args) == 0 {
+		exit(-1, "Usage sbr grep [-l|-c] [-i] [-w] <pattern> [-- pathspec...]\n")
+	}
+	pattern, pathspec := args[0], args[1:]
+	if len(pathspec) > 0 && pathspec[0] == "--" {
+		pathspec = pathspec[1:]
+	}
+
+	workspace, err := sbr.FindWorkspace(os.Getwd())
+	if err != nil {
+		exit(CodeNoWorkingDir, "%v\n", err)
+	}
+
+	gitargs := make([]string, 0, 10)
+	switch {
+	case *c.files:
+		gitargs = append(gitargs, "-l")
+	case *c.count:
+		gitargs = append(gitargs, "-c")
+	default:
+		gitargs = append(gitargs, "-n")
+	}
+	if *c.ignoreCase {
+		gitargs = append(gitargs, "-i")
+	}
+	if *c.word {
+		gitargs = append(gitargs, "-w")
+	}
+	gitargs = append(gitargs, "-e", pattern, "--")
+	gitargs = append(gitargs, pathspec...)
+
+	// grep all repositories concurrently, but print them in path order
+	all := workspace.Select(c.filter.FilterCmd())
+	matches := make([][]string, len(all))
+	errs := make([]error, len(all))
+	var waiter sync.WaitGroup
+	for i, prj := range all {
+		waiter.Add(1)
+		go func(i int, prj string) {
+			defer waiter.Done()
+			matches[i], errs[i] = git.Grep(prj, gitargs...)
+		}(i, prj)
+	}
+	waiter.Wait()
+
+	found, failed := 0, 0
+	for i, prj := range all {
+		rel, err := filepath.Rel(workspace.Wd(), prj)
+		if err != nil {
+			rel = prj
+		}
+		if errs[i] != nil {
+			fmt.Fprintf(os.Stderr, "\033[00;31m%s\033[00m: %v\n", rel, errs[i])
+			failed++
+			continue
+		}
+		prefix := rel + string(filepath.Separator)
+		if rel == "." {
+			prefix = ""
+		}
+		for _, line := range matches[i] {
+			// all lines start with the file name, relative to the repository
+			fmt.Println(prefix + line)
+		}
+		found += len(matches[i])
+	}
+
+	switch {
+	case failed > 0:
+		exit(CodeExecFailed, "%v repositories could not be searched\n", failed)
+	case found == 0:
+		os.Exit(1) // like grep
+	}
+}
@@ -0,0 +1,114 @@
+package cmd
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/ericaro/sbr/sbr"
+)
+
+type LogCmd struct {
+	since, until, author *string
+	oneline, stat, json  *bool
+	filter               FilterFlags
+}
+
+func (c *LogCmd) Flags(fs *flag.FlagSet) {
+	c.since = fs.String("since", "", "show commits more recent than a date (e.g. 'yesterday', '2 weeks ago', '2015-06-01')")
+	c.until = fs.String("until", "", "show commits older than a date")
+	c.author = fs.String("author", "", "show commits whose author matches the pattern")
+	c.oneline = fs.Bool("oneline", false, "print each commit on a single line")
+	c.stat = fs.Bool("stat", false, "print the diff stat of each commit")
+	c.json = fs.Bool("json", false, "print one JSON object per commit")
+	c.filter.Flags(fs)
+}
+
+func (c *LogCmd) Run(args []string) {
+
+	workspace, err := sbr.FindWorkspace(os.Getwd())
+	if err != nil {
+		exit(CodeNoWorkingDir, "%v\n", err)
+	}
+
+	//build git log arguments, the rev-range is passed as is.
+	gitargs := make([]string, 0, 5)
+	if *c.since != "" {
+		gitargs = append(gitargs, "--since="+*c.since)
+	}
+	if *c.until != "" {
+		gitargs = append(gitargs, "--until="+*c.until)
+	}
+	if *c.author != "" {
+		gitargs = append(gitargs, "--author="+*c.author)
+	}
+	if *c.stat {
+		gitargs = append(gitargs, "--stat")
+	}
+	gitargs = append(gitargs, args...)
+
+	commits, errs := workspace.Log(workspace.Select(c.filter.FilterCmd()), gitargs...)
+	failed := make([]string, 0, len(errs))
+	for rel := range errs {
+		failed = append(failed, rel)
+	}
+	sort.Strings(failed)
+	for _, rel := range failed {
+		fmt.Fprintf(os.Stderr, "\033[00;31mCannot read log of %s\033[00m: %v\n", rel, errs[rel])
+	}
+
+	switch {
+	case *c.json:
+		enc := json.NewEncoder(os.Stdout)
+		for _, x := range commits {
+			enc.Encode(struct {
+				Rel      string    `json:"rel"`
+				Sha      string    `json:"sha"`
+				Author   string    `json:"author"`
+				Email    string    `json:"email"`
+				Date     time.Time `json:"date"` // the commit date, commits are sorted by it
+				Authored time.Time `json:"author_date"`
+				Subject  string    `json:"subject"`
+				Body     string    `json:"body,omitempty"`
+				Stat     string    `json:"stat,omitempty"`
+			}{x.Rel, x.Sha, x.Author, x.Email, x.CommitDate, x.Date, x.Subject, x.Body, x.Stat})
+		}
+	case *c.oneline:
+		w := tabwriter.NewWriter(os.Stdout, 4, 8, 2, ' ', 0)
+		for _, x := range commits {
+			fmt.Fprintf(w, "\033[00;33m%s\033[00m\t\033[00;36m%s\033[00m\t%s\n", short(x.Sha), x.Rel, x.Subject)
+			if x.Stat != "" {
+				w.Flush() // stat lines are not part of the table
+				fmt.Println(x.Stat)
+			}
+		}
+		w.Flush()
+	default:
+		for _, x := range commits {
+			fmt.Printf("\033[00;33mcommit %s\033[00m (\033[00;36m%s\033[00m)\n", x.Sha, x.Rel)
+			fmt.Printf("Author: %s <%s>\n", x.Author, x.Email)
+			fmt.Printf("Date:   %s\n\n", x.CommitDate.Format("Mon Jan 2 15:04:05 2006 -0700")) // commits are sorted by this date
+			fmt.Printf("    %s\n", x.Subject)
+			if x.Body != "" {
+				fmt.Printf("\n    %s\n", strings.Replace(x.Body, "\n", "\n    ", -1))
+			}
+			if x.Stat != "" {
+				fmt.Printf("\n%s\n", x.Stat)
+			}
+			fmt.Println()
+		}
+	}
+}
+
+//short returns the abbreviated sha1
+func short(sha string) string {
+	if len(sha) > 7 {
+		return sha[:7]
+	}
+	return sha
+}
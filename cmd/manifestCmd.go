@@ -0,0 +1,55 @@
+package cmd
+
+import (
+	"flag"
+	"os"
+
+	"github.com/ericaro/sbr/sbr"
+)
+
+type ManifestCmd struct {
+	output *string
+}
+
+func (c *ManifestCmd) Flags(fs *flag.FlagSet) {
+	c.output = fs.String("o", "", "write the manifest to this file instead of stdout")
+}
+
+func (c *ManifestCmd) Run(args []string) {
+
+	workspace, err := sbr.FindWorkspace(os.Getwd())
+	if err != nil {
+		exit(CodeNoWorkingDir, "%v\n", err)
+	}
+
+	m, err := workspace.Manifest()
+	if err != nil {
+		exit(-1, "Cannot compute the manifest: %v\n", err)
+	}
+
+	if *c.output == "" {
+		if _, err := m.WriteTo(os.Stdout); err != nil {
+			exit(-1, "Cannot write manifest: %v\n", err)
+		}
+		return
+	}
+	f, err := os.Create(*c.output)
+	if err != nil {
+		exit(-1, "Cannot write manifest: %v\n", err)
+	}
+	if _, err := m.WriteTo(f); err != nil {
+		f.Close()
+		exit(-1, "Cannot write manifest: %v\n", err)
+	}
+	if err := f.Close(); err != nil {
+		exit(-1, "Cannot write manifest: %v\n", err)
+	}
+}
+
+//readManifest reads a workspace state: either a manifest file, or a revision (tag, branch) applied to all repositories.
+func readManifest(workspace *sbr.Workspace, spec string) (sbr.Manifest, error) {
+	if _, err := os.Stat(spec); err == nil {
+		return sbr.ReadManifestFile(spec)
+	}
+	return workspace.TagManifest(spec)
+}
@@ -0,0 +1,12 @@
+//go:build windows
+// +build windows
+
+package cmd
+
+import "os/exec"
+
+//setProcessGroup is a no op on windows.
+func setProcessGroup(cmd *exec.Cmd) {}
+
+//killProcessGroup only kills the command itself on windows.
+func killProcessGroup(cmd *exec.Cmd) error { return cmd.Process.Kill() }
@@ -0,0 +1,185 @@
+package cmd
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"text/tabwriter"
+
+	"github.com/ericaro/sbr/git"
+	"github.com/ericaro/sbr/sbr"
+)
+
+type PushCmd struct {
+	dry    *bool
+	filter FilterFlags
+}
+
+func (c *PushCmd) Flags(fs *flag.FlagSet) {
+	c.dry = fs.Bool("n", false, "dry run: only check and print what would be pushed")
+	c.filter.Flags(fs)
+}
+
+//push is the state of a repository before a push.
+type push struct {
+	prj, rel  string
+	branch    string
+	ahead     int    // commits to push
+	behind    int    // commits to pull
+	newBranch bool   // the branch does not exist on the remote yet
+	problem   string // why it cannot be pushed
+	err       error  // push error
+}
+
+//check fetches the repository, and computes what would be pushed.
+func (p *push) check() {
+	if err := git.Fetch(p.prj); err != nil {
+		p.problem = "cannot fetch: " + firstLine(err.Error())
+		return
+	}
+	branch, err := git.Branch(p.prj)
+	if err != nil || branch == "HEAD" {
+		return // detached: nothing to push
+	}
+	p.branch = branch
+	if changed, err := sbr.HasLocalChanges(p.prj); err != nil {
+		p.problem = "cannot read the status: " + firstLine(err.Error())
+		return
+	} else if changed {
+		p.problem = "dirty, commit or stash changes"
+		return
+	}
+	if !git.RefExists(p.prj, "refs/remotes/origin/"+branch) {
+		p.newBranch = true
+		return
+	}
+	p.ahead, p.behind, err = git.RevListCount(p.prj, "HEAD", "origin/"+branch)
+	switch {
+	case err != nil:
+		p.problem = firstLine(err.Error())
+	case p.ahead > 0 && p.behind > 0:
+		p.problem = fmt.Sprintf("diverged (%v ahead, %v behind), pull first", p.ahead, p.behind)
+	}
+}
+
+//pending returns true if there is something to push.
+func (p *push) pending() bool { return p.problem == "" && (p.ahead > 0 || p.newBranch) }
+
+func (c *PushCmd) Run(args []string) {
+
+	workspace, err := sbr.FindWorkspace(os.Getwd())
+	if err != nil {
+		exit(CodeNoWorkingDir, "%v\n", err)
+	}
+
+	// the workspace itself is pushed last (its '.sbr' must not refer to unpushed branches)
+	var top *push
+	subs := make([]*push, 0, 100)
+	for _, prj := range workspace.Select(c.filter.FilterCmd()) {
+		p := &push{prj: prj, rel: sbr.NewRepository(workspace.Wd(), prj).Rel}
+		if prj == workspace.Wd() {
+			top = p
+		} else {
+			subs = append(subs, p)
+		}
+	}
+	all := subs
+	if top != nil {
+		all = append(all, top)
+	}
+
+	// pre flight: fetch and check everything
+	fmt.Printf("Fetching all...\n")
+	eachPush(all, (*push).check)
+
+	w := tabwriter.NewWriter(os.Stdout, 4, 8, 2, ' ', 0)
+	problems, pending := 0, 0
+	for _, p := range all {
+		switch {
+		case p.problem != "":
+			problems++
+			fmt.Fprintf(w, "\033[00;31mBLOCKED\033[00m\t%s\t%s\t%s\n", p.rel, p.branch, p.problem)
+		case p.pending():
+			pending++
+			fmt.Fprintf(w, "\033[00;34mPUSH   \033[00m\t%s\t%s\t%s\n", p.rel, p.branch, p.describe())
+		}
+	}
+	w.Flush()
+
+	switch {
+	case problems > 0:
+		exit(CodePushBlocked, "\n%v repositories cannot be pushed, nothing has been pushed\n", problems)
+	case pending == 0:
+		fmt.Println("Everything up-to-date")
+		return
+	case *c.dry:
+		return
+	}
+
+	// subrepositories first, then the workspace, only if they all succeeded
+	fmt.Println()
+	pushed := make([]*push, 0, pending)
+	for _, p := range subs {
+		if p.pending() {
+			pushed = append(pushed, p)
+		}
+	}
+	eachPush(pushed, (*push).push)
+	failed := 0
+	for _, p := range pushed {
+		if p.err != nil {
+			failed++
+		}
+	}
+	if top != nil && top.pending() {
+		if failed == 0 {
+			top.push()
+			if top.err != nil {
+				failed++
+			}
+		} else {
+			top.err = fmt.Errorf("not pushed: subrepositories failed")
+		}
+		pushed = append(pushed, top)
+	}
+
+	for _, p := range pushed {
+		if p.err != nil {
+			fmt.Fprintf(w, "\033[00;31mFAILED \033[00m\t%s\t%s\t%s\n", p.rel, p.branch, strings.Replace(p.err.Error(), "\n", "; ", -1))
+		} else {
+			fmt.Fprintf(w, "\033[00;32mPUSHED \033[00m\t%s\t%s\t%s\n", p.rel, p.branch, p.describe())
+		}
+	}
+	w.Flush()
+	if failed > 0 {
+		exit(CodeExecFailed, "\n%v repositories could not be pushed\n", failed)
+	}
+}
+
+//push pushes the branch.
+func (p *push) push() {
+	_, p.err = git.Push(p.prj, p.branch, p.newBranch, true)
+}
+
+//describe the commits to be pushed.
+func (p *push) describe() string {
+	if p.newBranch {
+		return "new branch"
+	}
+	return fmt.Sprintf("%v commits", p.ahead)
+}
+
+//eachPush calls f on every push, concurrently.
+func eachPush(all []*push, f func(*push)) {
+	var waiter sync.WaitGroup
+	for _, p := range all {
+		waiter.Add(1)
+		go func(p *push) {
+			defer waiter.Done()
+			f(p)
+		}(p)
+	}
+	waiter.Wait()
+}
@@ -0,0 +1,158 @@
+package cmd
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+	"text/tabwriter"
+
+	"github.com/ericaro/sbr/git"
+	"github.com/ericaro/sbr/sbr"
+)
+
+type ReplaceCmd struct {
+	glob, branch, message *string
+	apply                 *bool
+	filter                FilterFlags
+}
+
+func (c *ReplaceCmd) Flags(fs *flag.FlagSet) {
+	c.glob = fs.String("glob", "", "only edit files matching this pattern (e.g. '*.go', or 'cmd/*.go' to match the path)")
+	c.apply = fs.Bool("apply", false, "apply changes without confirmation")
+	c.branch = fs.String("branch", "", "create this branch in every touched repository, and commit changes in it")
+	c.message = fs.String("m", "", "commit changes in every touched repository with this message")
+	c.filter.Flags(fs)
+}
+
+func (c *ReplaceCmd) Run(args []string) {
+
+	if len(args) != 2 {
+		exit(-1, "Usage sbr replace [-glob pattern] [-apply] [-branch name] [-m msg] <regexp> <replacement>\n")
+	}
+	re, err := regexp.Compile(args[0])
+	if err != nil {
+		exit(-1, "Invalid regexp %q: %v\n", args[0], err)
+	}
+	repl := args[1]
+
+	workspace, err := sbr.FindWorkspace(os.Getwd())
+	if err != nil {
+		exit(CodeNoWorkingDir, "%v\n", err)
+	}
+
+	// compute edits concurrently
+	all := workspace.Select(c.filter.FilterCmd())
+	edits := make([][]sbr.Edit, len(all))
+	errs := make([]error, len(all))
+	var waiter sync.WaitGroup
+	for i, prj := range all {
+		waiter.Add(1)
+		go func(i int, prj string) {
+			defer waiter.Done()
+			edits[i], errs[i] = sbr.Replace(prj, re, repl, *c.glob)
+		}(i, prj)
+	}
+	waiter.Wait()
+
+	// preview, grouped by repository
+	files, repos := 0, 0
+	for i, prj := range all {
+		rel := sbr.NewRepository(workspace.Wd(), prj).Rel
+		if errs[i] != nil {
+			exit(-1, "Cannot search %s: %v\n", rel, errs[i])
+		}
+		if len(edits[i]) == 0 {
+			continue
+		}
+		fmt.Printf("\033[01;36m# %s\033[00m\n", rel)
+		for _, e := range edits[i] {
+			printDiff(e.Diff())
+		}
+		fmt.Println()
+		files += len(edits[i])
+		repos++
+	}
+	if files == 0 {
+		fmt.Println("Nothing to replace")
+		return
+	}
+
+	if !*c.apply && !confirm(fmt.Sprintf("Apply changes to %v files in %v repositories?", files, repos)) {
+		return
+	}
+
+	// commit only if asked
+	message := *c.message
+	if message == "" && *c.branch != "" {
+		message = fmt.Sprintf("Replace %q by %q", args[0], repl)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 4, 8, 2, ' ', 0)
+	failed := 0
+	for i, prj := range all {
+		if len(edits[i]) == 0 {
+			continue
+		}
+		rel := sbr.NewRepository(workspace.Wd(), prj).Rel
+		if err := c.replace(prj, edits[i], message); err != nil {
+			failed++
+			fmt.Fprintf(w, "\033[00;31mFAILED\033[00m\t%s\t%s\n", rel, strings.Replace(err.Error(), "\n", "; ", -1))
+			continue
+		}
+		fmt.Fprintf(w, "\033[00;32mEDITED\033[00m\t%s\t%v files\n", rel, len(edits[i]))
+	}
+	w.Flush()
+	if failed > 0 {
+		exit(CodeExecFailed, "%v repositories could not be edited\n", failed)
+	}
+}
+
+//replace applies the edits in the repository 'prj', optionally in a new branch, and commits them if 'message' is set.
+func (c *ReplaceCmd) replace(prj string, edits []sbr.Edit, message string) error {
+	if *c.branch != "" {
+		if err := git.Checkout(prj, *c.branch, true); err != nil {
+			return err
+		}
+	}
+	names := make([]string, 0, len(edits))
+	for _, e := range edits {
+		if err := e.Apply(); err != nil {
+			return err
+		}
+		names = append(names, e.Name)
+	}
+	if message == "" {
+		return nil
+	}
+	return git.CommitFiles(prj, message, names...) // not what the user had already staged
+}
+
+//printDiff prints a unified diff in color.
+func printDiff(diff string) {
+	for _, line := range strings.Split(strings.TrimSuffix(diff, "\n"), "\n") {
+		switch {
+		case strings.HasPrefix(line, "+++"), strings.HasPrefix(line, "---"):
+			fmt.Printf("\033[01m%s\033[00m\n", line)
+		case strings.HasPrefix(line, "@@"):
+			fmt.Printf("\033[00;36m%s\033[00m\n", line)
+		case strings.HasPrefix(line, "+"):
+			fmt.Printf("\033[00;32m%s\033[00m\n", line)
+		case strings.HasPrefix(line, "-"):
+			fmt.Printf("\033[00;31m%s\033[00m\n", line)
+		default:
+			fmt.Println(line)
+		}
+	}
+}
+
+//confirm asks a yes/no question on the terminal, no is the default.
+func confirm(question string) bool {
+	fmt.Printf("%s [y/N] ", question)
+	answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return answer == "y" || answer == "yes"
+}
@@ -0,0 +1,199 @@
+package cmd
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+)
+
+//this file contains ExecutionProcessor that write reports for other tools (CI servers, test harnesses)
+
+//JUnitReport returns an ExecutionProcessor that writes a JUnit XML report to w, one test case per subrepository.
+//
+// commands that exit with a non zero code are failures, commands that could not complete (not started, timeout, cancelled) are errors.
+func JUnitReport(w io.Writer) ExecutionProcessor {
+	return func(source <-chan Execution) {
+		all := collect(source)
+
+		type result struct {
+			Message string `xml:"message,attr"`
+			Type    string `xml:"type,attr"`
+			Content string `xml:",chardata"`
+		}
+		type testcase struct {
+			Name      string  `xml:"name,attr"`
+			Classname string  `xml:"classname,attr"`
+			Time      string  `xml:"time,attr"` // in seconds
+			Failure   *result `xml:"failure,omitempty"`
+			Error     *result `xml:"error,omitempty"`
+			SystemOut string  `xml:"system-out,omitempty"`
+		}
+		type testsuite struct {
+			XMLName  xml.Name   `xml:"testsuite"`
+			Name     string     `xml:"name,attr"`
+			Tests    int        `xml:"tests,attr"`
+			Failures int        `xml:"failures,attr"`
+			Errors   int        `xml:"errors,attr"`
+			Time     string     `xml:"time,attr"` // in seconds
+			Cases    []testcase `xml:"testcase"`
+		}
+
+		suite := testsuite{Tests: len(all)}
+		var total float64
+		for _, x := range all {
+			if suite.Name == "" {
+				suite.Name = strings.TrimSpace(x.Cmd + " " + strings.Join(x.Args, " "))
+			}
+			c := testcase{Name: x.Rel, Classname: "sbr", Time: seconds(x.Duration.Seconds()), SystemOut: x.Result}
+			switch {
+			case !x.Failed():
+			case x.Code > 0:
+				suite.Failures++
+				c.Failure = &result{Message: x.Status(), Type: "exit", Content: x.Stderr}
+			default:
+				suite.Errors++
+				c.Error = &result{Message: x.Status(), Type: "error", Content: x.Stderr}
+			}
+			total += x.Duration.Seconds()
+			suite.Cases = append(suite.Cases, c)
+		}
+		suite.Time = seconds(total)
+
+		fmt.Fprint(w, xml.Header)
+		fmt.Fprintln(w, "<testsuites>")
+		enc := xml.NewEncoder(w)
+		enc.Indent("  ", "  ")
+		if err := enc.Encode(suite); err != nil {
+			fmt.Fprintf(os.Stderr, "Cannot write junit report: %v\n", err)
+		}
+		fmt.Fprintln(w, "\n</testsuites>")
+	}
+}
+
+//TAPReport returns an ExecutionProcessor that writes a TAP (version 13) report to w, one test per subrepository.
+func TAPReport(w io.Writer) ExecutionProcessor {
+	return func(source <-chan Execution) {
+		all := collect(source)
+
+		fmt.Fprintf(w, "TAP version 13\n1..%d\n", len(all))
+		for i, x := range all {
+			if x.Failed() {
+				fmt.Fprintf(w, "not ok %d - %s # %s\n", i+1, x.Rel, x.Status())
+			} else {
+				fmt.Fprintf(w, "ok %d - %s\n", i+1, x.Rel)
+			}
+			// diagnostic as a yaml block
+			fmt.Fprintf(w, "  ---\n  duration_ms: %d\n  exit: %d\n", x.Duration.Nanoseconds()/1e6, x.Code)
+			if x.Result != "" {
+				fmt.Fprintf(w, "  output: |\n    %s\n", strings.Replace(x.Result, "\n", "\n    ", -1))
+			}
+			fmt.Fprintf(w, "  ...\n")
+		}
+	}
+}
+
+//seconds formats a number of seconds with a millisecond precision.
+func seconds(s float64) string { return fmt.Sprintf("%.3f", s) }
+
+//collect flushes the source into a slice sorted by Rel
+func collect(source <-chan Execution) []Execution {
+	all := make([]Execution, 0, 100)
+	for x := range source {
+		all = append(all, x)
+	}
+	sort.Sort(byExecRel(all))
+	return all
+}
+
+//byExecRel to sort any slice of Execution by their Rel
+type byExecRel []Execution
+
+func (a byExecRel) Len() int           { return len(a) }
+func (a byExecRel) Swap(i, j int)      { a[i], a[j] = a[j], a[i] }
+func (a byExecRel) Less(i, j int) bool { return a[i].Rel < a[j].Rel }
+
+//Reports is a flag.Value that collects report specifications: "<format>:<file>" ('-' for stdout).
+type Reports []string
+
+func (r *Reports) String() string { return strings.Join(*r, ",") }
+
+func (r *Reports) Set(spec string) error {
+	if _, _, err := parseReport(spec); err != nil {
+		return err
+	}
+	*r = append(*r, spec)
+	return nil
+}
+
+//Open creates all report files, and returns their processors, and the reports to be closed when done.
+//
+// Closing a report returns the first error that occurred while writing it.
+func (r *Reports) Open() (xps []ExecutionProcessor, closers []io.Closer, err error) {
+	for _, spec := range *r {
+		format, file, _ := parseReport(spec)
+		w := &reportWriter{w: os.Stdout, name: file}
+		if file != "-" {
+			f, err := os.Create(file)
+			if err != nil {
+				return xps, closers, err
+			}
+			w.w, w.c = f, f
+		}
+		closers = append(closers, w)
+		switch format {
+		case "junit":
+			xps = append(xps, JUnitReport(w))
+		case "tap":
+			xps = append(xps, TAPReport(w))
+		}
+	}
+	return
+}
+
+//reportWriter remembers the first write error of a report, so that processors do not have to check every write.
+type reportWriter struct {
+	w    io.Writer
+	c    io.Closer // nil for stdout
+	name string
+	err  error
+}
+
+func (r *reportWriter) Write(p []byte) (n int, err error) {
+	if r.err != nil {
+		return 0, r.err
+	}
+	n, r.err = r.w.Write(p)
+	return n, r.err
+}
+
+//Close closes the report file, and returns the first error.
+func (r *reportWriter) Close() error {
+	if r.c != nil {
+		if err := r.c.Close(); r.err == nil {
+			r.err = err
+		}
+	}
+	if r.err != nil {
+		return fmt.Errorf("cannot write report %s: %v", r.name, r.err)
+	}
+	return nil
+}
+
+//parseReport splits a report specification.
+func parseReport(spec string) (format, file string, err error) {
+	i := strings.Index(spec, ":")
+	if i < 0 {
+		return "", "", fmt.Errorf("invalid report %q, expecting <format>:<file>", spec)
+	}
+	format, file = spec[:i], spec[i+1:]
+	if format != "junit" && format != "tap" {
+		return "", "", fmt.Errorf("unknown report format %q, expecting 'junit' or 'tap'", format)
+	}
+	if file == "" {
+		return "", "", fmt.Errorf("missing report file in %q ('-' for stdout)", spec)
+	}
+	return
+}
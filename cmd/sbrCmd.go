@@ -16,15 +16,29 @@ func NewSbrCmd() SbrCmd {
 	c := SbrCmd{command.New()}
 	c.On("clone", "<remote> [path]", "clone a remote repository, and then checkout it's .sbr", &CloneCmd{})
 	c.On("checkout", "", "pull top; clone new dependencies; pull all other dependencies (deprecated dependencies can be pruned using -f option)", &CheckoutCmd{})
+	c.On("switch", "<branch>", "switch the workspace to another branch, and apply its '.sbr' (switched back on failure)", &SwitchCmd{})
 	c.On("fetch", "", "fetch all current subrepositories", &FetchCmd{})
-	c.On("version", "", "compute the sha1 of all dependencies' sha1", &VersionCmd{})
+	c.On("sync", "", "fetch, and rebase local commits onto the upstream in all repositories, aborting on conflicts", &SyncCmd{})
+	c.On("stash", "[push [-m msg] | list | pop | drop]", "stash local changes of all repositories together, and restore them", &StashCmd{})
+	c.On("push", "", "check that all repositories can be pushed, then push subrepositories, and the workspace last", &PushCmd{})
+	c.On("version", "", "compute the sha1 of the workspace and all declared dependencies' sha1", &VersionCmd{})
+	c.On("describe", "", "print a human readable workspace version, from the most recent tag common to all subrepositories", &DescribeCmd{})
+	c.On("manifest", "", "print the current workspace state: every repository pinned to its current commit", &ManifestCmd{})
+	c.On("changelog", "<from> <to>", "print, in Markdown, commits per repository between two tags or manifests", &ChangelogCmd{})
 	//these are edits
+	c.On("branch", "<name> <path>...", "start a feature branch in some subrepositories, and declare it in '.sbr' (-finish to switch them back)", &BranchCmd{})
+	c.On("commit", "-m <msg> [path...]", "commit changes in all subrepositories with the same message, and a shared trailer", &CommitCmd{})
+	c.On("bisect", "start <good> <bad> | good | bad | run <command> | reset", "find the subrepository commit that broke the workspace, between two manifests or tags", &BisectCmd{})
 	c.On("diff", "", "list subrepositories to be added to or removed from '.sbr'", &DiffCmd{})
 
 	// utils
 	c.On("x", "<command> <args>", "exec arbitrary command on each subrepository", &ExecCmd{})
-	c.On("status", "", "count commits between HEAD and 'upstream'", &StatusCmd{})
+	c.On("status", "[ref]", "count commits between HEAD and 'upstream' (or 'ref'), and report drifts from '.sbr'", &StatusCmd{})
+	c.On("log", "[rev-range]", "print commits of all subrepositories in a single chronological log", &LogCmd{})
+	c.On("grep", "<pattern> [-- pathspec...]", "search all subrepositories with 'git grep'", &GrepCmd{})
+	c.On("replace", "<regexp> <replacement>", "replace in files tracked by all subrepositories, after a preview", &ReplaceCmd{})
 	c.On("format", " ", "rewrite current '.sbr' into a cannonical format", &FormatCmd{})
+	c.On("deps", "", "report go imports missing from '.sbr', unused subrepositories, and their dependency graph", &DepsCmd{})
 
 	// CI subcommands
 	ci := command.New()
@@ -38,6 +52,7 @@ func NewSbrCmd() SbrCmd {
 	c.On("help", "[sections...]", "display sections summary, or section details", help.Command)
 	help.Section("format", "sbr format description", SbrFormatMd)
 	help.Section("ci", "CI server manual", CIServerMd)
+	help.Section("filter", "repository selection expressions", FilterMd)
 	help.Section("protocol", "CI server protocol", string(format.CIProtocolMd))
 
 	return c
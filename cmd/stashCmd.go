@@ -0,0 +1,126 @@
+package cmd
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/ericaro/sbr/sbr"
+)
+
+type StashCmd struct{}
+
+func (c *StashCmd) Flags(fs *flag.FlagSet) {}
+
+func (c *StashCmd) Run(args []string) {
+
+	workspace, err := sbr.FindWorkspace(os.Getwd())
+	if err != nil {
+		exit(CodeNoWorkingDir, "%v\n", err)
+	}
+
+	command := "push"
+	if len(args) > 0 {
+		command, args = args[0], args[1:]
+	}
+
+	switch command {
+	case "push":
+		fs := flag.NewFlagSet("push", flag.ExitOnError)
+		message := fs.String("m", "", "the stash message")
+		fs.Parse(args)
+		if *message == "" {
+			*message = "WIP"
+		}
+		stash, errs, err := workspace.StashPush(*message)
+		printStashErrors(errs)
+		if err != nil {
+			exit(-1, "Cannot record the workspace stash: %v\n", err)
+		}
+		if len(stash.Repos) == 0 {
+			fmt.Println("No local changes to save")
+			return
+		}
+		for _, p := range stash.Repos {
+			fmt.Printf("     Stashed '%s'\n", p.Rel())
+		}
+		fmt.Printf("Saved workspace stash@{0}: %s\n", stash.Message)
+		if len(errs) > 0 {
+			os.Exit(CodeExecFailed)
+		}
+
+	case "list":
+		stashes, err := workspace.Stashes()
+		if err != nil {
+			exit(-1, "Cannot read workspace stashes: %v\n", err)
+		}
+		w := tabwriter.NewWriter(os.Stdout, 4, 8, 2, ' ', 0)
+		for i, s := range stashes {
+			rels := make([]string, 0, len(s.Repos))
+			for _, p := range s.Repos {
+				rels = append(rels, p.Rel())
+			}
+			fmt.Fprintf(w, "stash@{%d}:\t%s\t%s\t%s\n", i, s.Date.Format("2006-01-02 15:04"), s.Message, strings.Join(rels, ", "))
+		}
+		w.Flush()
+
+	case "pop", "drop":
+		i := 0
+		if len(args) > 0 {
+			i, err = parseStashRef(args[0])
+			if err != nil {
+				exit(-1, "%v\n", err)
+			}
+		}
+		var stash sbr.Stash
+		var errs map[string]error
+		if command == "pop" {
+			stash, errs, err = workspace.StashPop(i)
+		} else {
+			stash, errs, err = workspace.StashDrop(i)
+		}
+		if err != nil {
+			exit(-1, "Cannot %s workspace stash@{%d}: %v\n", command, i, err)
+		}
+		for _, p := range stash.Repos {
+			if _, failed := errs[p.Rel()]; !failed {
+				fmt.Printf("     %s '%s'\n", map[string]string{"pop": "Popped", "drop": "Dropped"}[command], p.Rel())
+			}
+		}
+		printStashErrors(errs)
+		if len(errs) > 0 {
+			exit(CodeExecFailed, "%v repositories failed, they are kept in workspace stash@{%d}\n", len(errs), i)
+		}
+		fmt.Printf("Dropped workspace stash@{%d}: %s\n", i, stash.Message)
+
+	default:
+		exit(-1, "Usage sbr stash [push [-m msg] | list | pop [stash] | drop [stash]]\n")
+	}
+}
+
+//parseStashRef parses "stash@{n}" or "n".
+func parseStashRef(ref string) (int, error) {
+	s := strings.TrimSuffix(strings.TrimPrefix(ref, "stash@{"), "}")
+	i, err := strconv.Atoi(s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid stash %q, expecting stash@{n}", ref)
+	}
+	return i, nil
+}
+
+//printStashErrors prints errors by repository, in path order (conflicts are summarized).
+func printStashErrors(errs map[string]error) {
+	rels := make([]string, 0, len(errs))
+	for rel := range errs {
+		rels = append(rels, rel)
+	}
+	sort.Strings(rels)
+	for _, rel := range rels {
+		msg := errs[rel].Error() // conflicts are a git.ConflictError, already summarized
+		fmt.Fprintf(os.Stderr, "\033[00;31mERR\033[00m  '%s': %s\n", rel, strings.Replace(msg, "\n", "\n     ", -1))
+	}
+}
@@ -1,105 +1,140 @@
 package cmd
 
 import (
+	"encoding/json"
 	"flag"
 	"fmt"
 	"os"
-	"path/filepath"
-	"sort"
 	"strconv"
 	"strings"
 	"text/tabwriter"
 
-	"github.com/ericaro/sbr/git"
 	"github.com/ericaro/sbr/sbr"
 )
 
 type StatusCmd struct {
-	short *bool
+	short     *bool
+	porcelain *bool
+	json      *bool
+	filter    FilterFlags
 }
 
 func (c *StatusCmd) Flags(fs *flag.FlagSet) {
 	c.short = fs.Bool("s", false, "print only repo that have differences")
+	c.porcelain = fs.Bool("porcelain", false, "print one line per repo, tab separated: rel, branch, declared branch, ahead, behind, dirty, stash, error ('?' for unknown numbers)")
+	c.json = fs.Bool("json", false, "print one JSON object per repo")
+	c.filter.Flags(fs)
 }
 func (c *StatusCmd) Run(args []string) {
 
-	//get the revision to compare to (defaulted to origin/master)
-
 	//creates a workspace to be able to read from/to sets
 	workspace, err := sbr.FindWorkspace(os.Getwd())
 	if err != nil {
 		exit(-1, "%v", err)
 	}
 
-	//get all path, and sort them in alpha order
-	all := workspace.ScanRel()
+	//get the revision to compare to (defaulted to the upstream)
+	// the ref can be a template, expanded for each subrepository (e.g. origin/{{.Branch}})
+	var ref func(r *sbr.Repository) (string, error)
+	switch len(args) {
+	case 0:
+	case 1:
+		tmpl, err := ParseArgsTemplate(args)
+		if err != nil {
+			exit(-1, "Invalid ref %q: %v", args[0], err)
+		}
+		declared := make(map[string]sbr.Sub)
+		subs, _ := workspace.Read()
+		for _, s := range subs {
+			declared[s.Rel()] = s
+		}
+		ref = func(r *sbr.Repository) (string, error) {
+			refs, err := tmpl.Expand(NewTarget(workspace.Wd(), r.Path, declared))
+			if err != nil {
+				return "", err
+			}
+			return refs[0], nil
+		}
+	default:
+		exit(-1, "Usage sbr status [-s] [-porcelain|-json] [ref]")
+	}
+
+	//get all selected path, sorted in alpha order, and their status
+	all := workspace.Status(workspace.Select(c.filter.FilterCmd()), ref)
+	if *c.short {
+		changed := make([]sbr.Status, 0, len(all))
+		for _, s := range all {
+			if !s.Clean() {
+				changed = append(changed, s)
+			}
+		}
+		all = changed
+	}
 
-	//little trick to keep project sorted.
-	// this is only possible when I execute sync commands.
-	sort.Strings(all)
+	switch {
+	case *c.json:
+		enc := json.NewEncoder(os.Stdout)
+		for _, s := range all {
+			enc.Encode(s)
+		}
+	case *c.porcelain:
+		for _, s := range all {
+			fmt.Printf("%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n", s.Rel, s.Branch, s.DeclaredBranch,
+				count(s.Ahead, s.CountErr), count(s.Behind, s.CountErr), count(s.Dirty, s.DirtyErr), count(s.Stash, s.StashErr), s.Error)
+		}
+	default:
+		printStatus(all)
+	}
+}
 
-	//basically just running  git rev-list on each subrepo
-	// left, right, err := git.RevListCountHead(x, branch)
-	// and all the rest is "printing" stuff
+//count formats a number for the porcelain format.
+func count(n int, err error) string {
+	if err != nil {
+		return "?"
+	}
+	return strconv.Itoa(n)
+}
 
+//printStatus prints a pretty table of all status, with a grand total.
+func printStatus(all []sbr.Status) {
 	//pretty tab printer
 	w := tabwriter.NewWriter(os.Stdout, 4, 8, 2, ' ', 0)
 
 	//we are going to print a gran total
 	var tW, tLeft, tRight int
 
-	for _, x := range all {
-
-		// the real deal
-
-		left, right, giterr := git.RevListCountHead(x)
-		tLeft += left
-		tRight += right
-		wd, werr := git.StatusWCL(x)
-		tW += wd
-
-		//computes the relative path (prettier to print)
-		rel, err := filepath.Rel(workspace.Wd(), x)
-		if err != nil {
-			rel = x // rel is only use for presentation
-		}
+	for _, s := range all {
+		tLeft += s.Ahead
+		tRight += s.Behind
+		tW += s.Dirty
 
 		//compute the left,right  string
-		l := strconv.FormatInt(int64(left), 10)  //Defualt
-		r := strconv.FormatInt(int64(right), 10) //default
-		iw := strconv.FormatInt(int64(wd), 10)   //default
+		l := strconv.FormatInt(int64(s.Ahead), 10)  //Defualt
+		r := strconv.FormatInt(int64(s.Behind), 10) //default
+		iw := strconv.FormatInt(int64(s.Dirty), 10) //default
 		//pretty print 0 as -
-		if left == 0 {
+		if s.Ahead == 0 {
 			l = "-"
 		}
-		if right == 0 {
+		if s.Behind == 0 {
 			r = "-"
 		}
-		if wd == 0 {
+		if s.Dirty == 0 {
 			iw = "-"
 		}
-
-		errmess := ""
-		if werr != nil { //pretty print err as ?
+		//pretty print err as ?
+		if s.DirtyErr != nil {
 			iw = "?"
-			errmess += strings.Replace(werr.Error(), "\n", "; ", -1)
 		}
-		if giterr != nil { //pretty print err as ?
+		if s.CountErr != nil {
 			l = "?"
 			r = "?"
-			errmess += strings.Replace(giterr.Error(), "\n", "; ", -1)
-		}
-
-		//equals if there is not changes
-		equals := left == 0 && right == 0 && wd == 0
-		if !*c.short || !equals { // print only if required
-			//and print
-			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%v\n", iw, l, r, rel, errmess)
 		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%v\n", iw, l, r, s.Rel, strings.Join(s.Drifts(), ", "), s.Error)
 	}
 
 	fmt.Fprintf(w, "\n")
-	fmt.Fprintf(w, "%v\t%v\t%v\t%s\t \n", tW, tLeft, tRight, "Total")
+	fmt.Fprintf(w, "%v\t%v\t%v\t%s\t\t \n", tW, tLeft, tRight, "Total")
 	w.Flush()
 	fmt.Println()
 }
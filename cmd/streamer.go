@@ -0,0 +1,95 @@
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"sync"
+)
+
+//colors used to tag repositories, in turn.
+var tagColors = []string{"32", "33", "34", "35", "36", "01;32", "01;33", "01;34", "01;35", "01;36"}
+
+//Streamer prints the output of concurrent commands line by line, as it arrives, each line prefixed by
+// the repository colored tag (like docker-compose logs).
+//
+// stdout lines are separated from the tag by '|', stderr lines by a red '!'. Lines are never interleaved.
+type Streamer struct {
+	w     io.Writer
+	mu    sync.Mutex
+	width int               // tags are padded to this width
+	color map[string]string // tag -> color
+}
+
+//NewStreamer creates a Streamer that writes to w.
+func NewStreamer(w io.Writer) *Streamer {
+	return &Streamer{w: w, color: make(map[string]string)}
+}
+
+//Register declares all tags in advance, so that they are aligned, and colored in order.
+func (s *Streamer) Register(tags ...string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, tag := range tags {
+		s.register(tag)
+	}
+}
+
+func (s *Streamer) register(tag string) string {
+	c, exists := s.color[tag]
+	if !exists {
+		c = tagColors[len(s.color)%len(tagColors)]
+		s.color[tag] = c
+		if len(tag) > s.width {
+			s.width = len(tag)
+		}
+	}
+	return c
+}
+
+//Writer returns a writer for the output of the 'tag' repository, stdout or stderr.
+//
+// It must be closed to print the last line if it is not terminated by a new line.
+func (s *Streamer) Writer(tag string, stderr bool) io.WriteCloser {
+	return &lineWriter{s: s, tag: tag, stderr: stderr}
+}
+
+//println writes one line, atomically.
+func (s *Streamer) println(tag string, stderr bool, line []byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sep := "|"
+	if stderr {
+		sep = "\033[00;31m!\033[00m"
+	}
+	fmt.Fprintf(s.w, "\033[00;%sm%-*s\033[00m %s %s\n", s.register(tag), s.width, tag, sep, line)
+}
+
+//lineWriter buffers writes until a complete line is available.
+type lineWriter struct {
+	s      *Streamer
+	tag    string
+	stderr bool
+	buf    bytes.Buffer
+}
+
+func (w *lineWriter) Write(p []byte) (n int, err error) {
+	w.buf.Write(p)
+	for {
+		i := bytes.IndexByte(w.buf.Bytes(), '\n')
+		if i < 0 {
+			return len(p), nil
+		}
+		line := w.buf.Next(i + 1)
+		w.s.println(w.tag, w.stderr, bytes.TrimRight(line, "\r\n"))
+	}
+}
+
+//Close prints the pending partial line, if any.
+func (w *lineWriter) Close() error {
+	if w.buf.Len() > 0 {
+		w.s.println(w.tag, w.stderr, w.buf.Bytes())
+		w.buf.Reset()
+	}
+	return nil
+}
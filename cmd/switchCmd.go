@@ -0,0 +1,35 @@
+package cmd
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/ericaro/sbr/sbr"
+)
+
+type SwitchCmd struct {
+	prune *bool
+}
+
+func (c *SwitchCmd) Flags(fs *flag.FlagSet) {
+	c.prune = fs.Bool("prune", false, "prune sub repositories that are not in the new .sbr file")
+}
+
+func (c *SwitchCmd) Run(args []string) {
+	if len(args) != 1 {
+		exit(-1, "Usage sbr switch [-prune] <branch>\n")
+	}
+
+	workspace, err := sbr.FindWorkspace(os.Getwd())
+	if err != nil {
+		exit(CodeNoWorkingDir, "%v\n", err)
+	}
+	ch := sbr.NewCheckouter(workspace, os.Stdout)
+	ch.SetPrune(*c.prune)
+
+	if err := ch.Switch(args[0]); err != nil {
+		exit(-1, "switch error: %v\n", err)
+	}
+	fmt.Printf("Workspace switched to %s\n", args[0])
+}
@@ -0,0 +1,121 @@
+package cmd
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"sync"
+	"text/tabwriter"
+
+	"github.com/ericaro/sbr/git"
+	"github.com/ericaro/sbr/sbr"
+)
+
+type SyncCmd struct {
+	filter FilterFlags
+}
+
+func (c *SyncCmd) Flags(fs *flag.FlagSet) {
+	c.filter.Flags(fs)
+}
+
+//sync states
+const (
+	syncUpToDate = iota
+	syncRebased
+	syncConflicted
+	syncSkipped
+	syncFailed
+)
+
+//syncResult is the result of a sync in a repository.
+type syncResult struct {
+	state         int
+	ahead, behind int // before the rebase
+	message       string
+}
+
+func (c *SyncCmd) Run(args []string) {
+
+	workspace, err := sbr.FindWorkspace(os.Getwd())
+	if err != nil {
+		exit(CodeNoWorkingDir, "%v\n", err)
+	}
+
+	all := workspace.Select(c.filter.FilterCmd())
+	results := make([]syncResult, len(all))
+	fmt.Printf("Syncing all...\n")
+	var waiter sync.WaitGroup
+	for i, prj := range all {
+		waiter.Add(1)
+		go func(i int, prj string) {
+			defer waiter.Done()
+			results[i] = syncRepository(prj)
+		}(i, prj)
+	}
+	waiter.Wait()
+
+	labels := map[int]string{
+		syncUpToDate:   "\033[00;32mUP-TO-DATE\033[00m",
+		syncRebased:    "\033[00;34mREBASED   \033[00m",
+		syncConflicted: "\033[00;31mCONFLICTED\033[00m",
+		syncSkipped:    "\033[00;33mSKIPPED   \033[00m",
+		syncFailed:     "\033[00;31mFAILED    \033[00m",
+	}
+	counts := make(map[int]int)
+	w := tabwriter.NewWriter(os.Stdout, 4, 8, 2, ' ', 0)
+	for i, prj := range all {
+		x := results[i]
+		counts[x.state]++
+		fmt.Fprintf(w, "%s\t%s\t%s\n", labels[x.state], sbr.NewRepository(workspace.Wd(), prj).Rel, x.message)
+	}
+	w.Flush()
+	fmt.Printf("\n%v UP-TO-DATE, %v REBASED, %v CONFLICTED, %v SKIPPED, %v FAILED\n",
+		counts[syncUpToDate], counts[syncRebased], counts[syncConflicted], counts[syncSkipped], counts[syncFailed])
+
+	if counts[syncConflicted]+counts[syncFailed] > 0 {
+		os.Exit(CodeExecFailed)
+	}
+}
+
+//syncRepository fetches, and rebases local commits onto the upstream.
+func syncRepository(prj string) (x syncResult) {
+	if err := git.Fetch(prj); err != nil {
+		return syncResult{state: syncFailed, message: "cannot fetch: " + firstLine(err.Error())}
+	}
+	ahead, behind, err := git.RevListCountHead(prj)
+	if err == git.ErrNoUpstream {
+		return syncResult{state: syncSkipped, message: "no upstream"}
+	}
+	if err != nil {
+		return syncResult{state: syncFailed, message: firstLine(err.Error())}
+	}
+	if behind == 0 {
+		x.state = syncUpToDate
+		if ahead > 0 {
+			x.message = fmt.Sprintf("%v commits to push", ahead)
+		}
+		return x
+	}
+
+	stashed, err := git.Rebase(prj, "@{u}")
+	conflict, conflicted := err.(*git.ConflictError)
+	switch {
+	case conflicted:
+		x.state = syncConflicted
+		x.message = fmt.Sprintf("rebase of %v local commits aborted, %v", ahead, conflict)
+	case err != nil:
+		x.state = syncFailed
+		x.message = "rebase aborted: " + firstLine(err.Error())
+	case stashed:
+		x.state = syncConflicted
+		x.message = "rebased, but local changes conflict: they are kept in the stash"
+	default:
+		x.state = syncRebased
+		x.message = fmt.Sprintf("%v commits pulled", behind)
+		if ahead > 0 {
+			x.message += fmt.Sprintf(", %v local commits rebased", ahead)
+		}
+	}
+	return x
+}
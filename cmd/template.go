@@ -0,0 +1,66 @@
+package cmd
+
+import (
+	"bytes"
+	"path/filepath"
+	"text/template"
+
+	"github.com/ericaro/sbr/git"
+	"github.com/ericaro/sbr/sbr"
+)
+
+//Target describes a subrepository to templated arguments.
+//
+// Remote and Branch come from the '.sbr' declaration, or from git when the subrepository is not declared.
+type Target struct {
+	Name   string // last element of the path
+	Rel    string // path relative to the workspace
+	Path   string // absolute path
+	Remote string
+	Branch string
+}
+
+//NewTarget creates the Target of the subrepository at 'path', given the declared subrepositories indexed by rel.
+func NewTarget(wd, path string, declared map[string]sbr.Sub) Target {
+	rel, err := filepath.Rel(wd, path)
+	if err != nil {
+		rel = path
+	}
+	t := Target{Name: filepath.Base(path), Rel: rel, Path: path}
+	if d, exists := declared[rel]; exists {
+		t.Remote, t.Branch = d.Remote(), d.Branch()
+	} else {
+		t.Remote, _ = git.RemoteOrigin(path)
+		t.Branch, _ = git.Branch(path)
+	}
+	return t
+}
+
+//ArgsTemplate holds command arguments as text/template to be expanded for each Target.
+type ArgsTemplate []*template.Template
+
+//ParseArgsTemplate parses each argument as a text/template.
+func ParseArgsTemplate(args []string) (t ArgsTemplate, err error) {
+	t = make(ArgsTemplate, len(args))
+	for i, arg := range args {
+		t[i], err = template.New("arg").Option("missingkey=error").Parse(arg)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return t, nil
+}
+
+//Expand executes all argument templates against the target.
+func (t ArgsTemplate) Expand(target Target) (args []string, err error) {
+	args = make([]string, len(t))
+	buf := new(bytes.Buffer)
+	for i, tmpl := range t {
+		buf.Reset()
+		if err = tmpl.Execute(buf, target); err != nil {
+			return nil, err
+		}
+		args[i] = buf.String()
+	}
+	return args, nil
+}
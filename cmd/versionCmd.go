@@ -1,13 +1,25 @@
 package cmd
 
 import (
+	"flag"
 	"fmt"
 	"os"
+	"strings"
 
 	"github.com/ericaro/sbr/sbr"
 )
 
-type VersionCmd struct{}
+type VersionCmd struct {
+	scan     *bool
+	worktree *bool
+	strict   *bool
+}
+
+func (c *VersionCmd) Flags(fs *flag.FlagSet) {
+	c.scan = fs.Bool("scan", false, "legacy version: the sha1 of all repositories found on the disk, declared or not")
+	c.worktree = fs.Bool("worktree", false, "also hash local changes (tracked and untracked files), and append '-dirty' if there are any")
+	c.strict = fs.Bool("strict", false, "fail if any repository has local changes")
+}
 
 func (c *VersionCmd) Run(args []string) {
 
@@ -15,10 +27,35 @@ func (c *VersionCmd) Run(args []string) {
 	if err != nil {
 		exit(-1, "%v", err)
 	}
+	worktree := *c.worktree || *c.strict
+
+	if *c.scan && !worktree {
+		v, err := workspace.Version()
+		if err != nil {
+			fmt.Printf("Cannot compute version: %v\n", err)
+		}
+		fmt.Printf("%x\n", v)
+		return
+	}
 
-	v, err := workspace.Version()
+	var v []byte
+	var dirty []string
+	prefix := sbr.VersionPrefix
+	if *c.scan {
+		v, dirty, err = workspace.WorktreeVersion()
+		prefix = ""
+	} else {
+		v, dirty, err = workspace.DeclaredVersion(worktree)
+	}
 	if err != nil {
-		fmt.Printf("Cannot compute version: %v\n", err)
+		exit(-1, "Cannot compute version: %v\n", err)
+	}
+	if *c.strict && len(dirty) > 0 {
+		exit(CodeUncommitted, "Uncommitted changes in %s\n", strings.Join(dirty, ", "))
+	}
+	suffix := ""
+	if len(dirty) > 0 {
+		suffix = "-dirty"
 	}
-	fmt.Printf("%x\n", v)
+	fmt.Printf("%s%x%s\n", prefix, v, suffix)
 }
@@ -1,8 +1,12 @@
 package git
 
 import (
+	"bytes"
+	"errors"
 	"fmt"
+	"os"
 	"os/exec"
+	"sort"
 	"strconv"
 	"strings"
 )
@@ -14,6 +18,33 @@ const (
 	DefaultTrimCut = "\n \t"
 )
 
+var (
+	ErrNoUpstream = errors.New("no upstream")
+)
+
+//ConflictError is returned when a command stops on conflicts: 'Files' are the unmerged paths.
+type ConflictError struct {
+	Files []string
+}
+
+func (e *ConflictError) Error() string { return "conflicts in " + strings.Join(e.Files, ", ") }
+
+//Unmerged returns the unmerged paths (conflicts), if any.
+func Unmerged(prj string) (files []string, err error) {
+	cmd := exec.Command("git", "diff", "-z", "--name-only", "--diff-filter=U")
+	cmd.Dir = prj
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		return nil, fmt.Errorf("failed to: %s$ git diff --name-only --diff-filter=U: %s %s", prj, err.Error(), strings.Trim(string(out), DefaultTrimCut))
+	}
+	for _, f := range strings.Split(string(out), "\x00") {
+		if f != "" {
+			files = append(files, f)
+		}
+	}
+	return files, nil
+}
+
 //Branch extract the current branch's name (HEAD)
 func Branch(prj string) (branch string, err error) {
 	cmd := exec.Command("git", "rev-parse", "--abbrev-ref", "HEAD")
@@ -137,9 +168,49 @@ func RevParseHead(prj string) (result string, err error) {
 	return result, nil
 }
 
-//RevParseHead read the current commit sha1
+//RevListCountHead counts commits to be pushed (left) and to be pulled (right) between HEAD and its upstream.
+//
+// It returns ErrNoUpstream if the current branch has no upstream (or HEAD is detached).
 func RevListCountHead(prj string) (left, right int, err error) {
-	cmd := exec.Command("git", "rev-list", "--count", "--left-right", "HEAD...@{u}")
+	upstream, err := upstreamRef(prj)
+	if err != nil {
+		return 0, 0, err
+	}
+	return RevListCount(prj, "HEAD", upstream)
+}
+
+//upstreamRef returns the full name of the current branch's upstream (e.g. "refs/remotes/origin/master").
+//
+// It returns ErrNoUpstream if HEAD is detached, if the branch has no upstream, or if it does not exist (anymore).
+// Only exit codes are checked, git messages depend on the locale.
+func upstreamRef(prj string) (upstream string, err error) {
+	cmd := exec.Command("git", "symbolic-ref", "-q", "HEAD")
+	cmd.Dir = prj
+	out, err := cmd.Output()
+	if exit, ok := err.(*exec.ExitError); ok && exit.ExitCode() == 1 { // detached
+		return "", ErrNoUpstream
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to: %s$ git symbolic-ref HEAD: %s", prj, err.Error())
+	}
+	branch := strings.Trim(string(out), DefaultTrimCut)
+
+	cmd = exec.Command("git", "for-each-ref", "--format=%(upstream)", branch)
+	cmd.Dir = prj
+	out, err = cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("failed to: %s$ git for-each-ref --format=%%(upstream) %s: %s", prj, branch, err.Error())
+	}
+	upstream = strings.Trim(string(out), DefaultTrimCut)
+	if upstream == "" || !RefExists(prj, upstream) {
+		return "", ErrNoUpstream
+	}
+	return upstream, nil
+}
+
+//RevListCount counts commits reachable from 'from' but not from 'to' (left), and reachable from 'to' but not from 'from' (right).
+func RevListCount(prj, from, to string) (left, right int, err error) {
+	cmd := exec.Command("git", "rev-list", "--count", "--left-right", from+"..."+to)
 	cmd.Dir = prj
 	out, err := cmd.CombinedOutput()
 	result := strings.Trim(string(out), DefaultTrimCut)
@@ -178,3 +249,386 @@ func StatusWCL(prj string) (changes int, err error) {
 	}
 
 }
+
+//git stash list | wc -l
+func StashCount(prj string) (stashes int, err error) {
+	cmd := exec.Command("git", "stash", "list")
+	cmd.Dir = prj
+	out, err := cmd.CombinedOutput()
+	result := strings.Trim(string(out), DefaultTrimCut)
+	if err != nil {
+		return 0, fmt.Errorf("execution error: %s$ git %s -> error %v: %s", prj, strings.Join(cmd.Args, " "), err, result)
+	}
+	if result == "" {
+		return 0, nil
+	}
+	return len(strings.Split(result, "\n")), nil
+}
+
+//Show returns the content of the file at 'path' (relative to the repository root) in revision 'rev'.
+func Show(prj, rev, path string) (content []byte, err error) {
+	cmd := exec.Command("git", "show", rev+":"+path)
+	cmd.Dir = prj
+	stderr := new(bytes.Buffer)
+	cmd.Stderr = stderr
+	content, err = cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to: %s$ git show %s:%s: %s %s", prj, rev, path, err.Error(), strings.Trim(stderr.String(), DefaultTrimCut))
+	}
+	return content, nil
+}
+
+//Grep runs git grep with 'args' and returns matching lines.
+//
+// no match is not an error: it returns no lines.
+func Grep(prj string, args ...string) (lines []string, err error) {
+	cmd := exec.Command("git", append([]string{"grep"}, args...)...)
+	cmd.Dir = prj
+	stderr := new(bytes.Buffer)
+	cmd.Stderr = stderr
+	out, err := cmd.Output()
+	if err != nil {
+		if exit, ok := err.(*exec.ExitError); ok && exit.ExitCode() == 1 && stderr.Len() == 0 {
+			return nil, nil // no match
+		}
+		return nil, fmt.Errorf("failed to: %s$ git grep %s: %s %s", prj, strings.Join(args, " "), err.Error(), strings.Trim(stderr.String(), DefaultTrimCut))
+	}
+	result := strings.TrimRight(string(out), "\n")
+	if result == "" {
+		return nil, nil
+	}
+	return strings.Split(result, "\n"), nil
+}
+
+//LsFiles lists the files tracked by the repository (slash separated, relative to the repository).
+func LsFiles(prj string) (files []string, err error) {
+	cmd := exec.Command("git", "ls-files", "-z")
+	cmd.Dir = prj
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to: %s$ git ls-files: %s", prj, err.Error())
+	}
+	result := strings.TrimRight(string(out), "\x00")
+	if result == "" {
+		return nil, nil
+	}
+	return strings.Split(result, "\x00"), nil
+}
+
+//CommitFiles commits the current content of 'files' only, whatever is in the index for other files.
+func CommitFiles(prj, msg string, files ...string) (err error) {
+	cmd := exec.Command("git", append([]string{"commit", "-q", "-F", "-", "--"}, files...)...)
+	cmd.Dir = prj
+	cmd.Stdin = strings.NewReader(msg)
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("failed to: %s$ git commit: %s %s", prj, err.Error(), string(out))
+	}
+	return nil
+}
+
+//CommitChanges records changes in the index (or all tracked files, if 'all') with the message 'msg'.
+//
+// 'trailers' ("Token: value") are added to the trailers of the message, as git does it.
+func CommitChanges(prj, msg string, all bool, trailers ...string) (err error) {
+	args := []string{"commit", "-q", "-F", "-"}
+	if all {
+		args = append(args, "-a")
+	}
+	for _, t := range trailers {
+		args = append(args, "--trailer", t)
+	}
+	cmd := exec.Command("git", args...)
+	cmd.Dir = prj
+	cmd.Stdin = strings.NewReader(msg)
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("failed to: %s$ git commit: %s %s", prj, err.Error(), string(out))
+	}
+	return nil
+}
+
+//CheckoutFrom creates the branch 'branch' from 'start' and checks it out
+func CheckoutFrom(prj, branch, start string) (err error) {
+	cmd := exec.Command("git", "checkout", "--no-track", "-b", branch, start)
+	cmd.Dir = prj
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("%s: %v", strings.Trim(string(out), DefaultTrimCut), err)
+	}
+	return nil
+}
+
+//HasChanges returns true if there are changes to be committed: in the index, or in all tracked files if 'all'.
+//
+// Without any commit yet, changes are relative to the empty tree.
+func HasChanges(prj string, all bool) (changes bool, err error) {
+	args := []string{"diff", "--quiet", "--cached"}
+	if all {
+		head := "HEAD"
+		if !RefExists(prj, "HEAD") { // unborn
+			if head, err = emptyTree(prj); err != nil {
+				return false, err
+			}
+		}
+		args = []string{"diff", "--quiet", head}
+	}
+	cmd := exec.Command("git", args...)
+	cmd.Dir = prj
+	out, err := cmd.CombinedOutput()
+	if exit, ok := err.(*exec.ExitError); ok && exit.ExitCode() == 1 {
+		return true, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to: %s$ git %s: %s %s", prj, strings.Join(args, " "), err.Error(), string(out))
+	}
+	return false, nil
+}
+
+//emptyTree returns the sha1 of the empty tree (in the repository object format).
+func emptyTree(prj string) (sha string, err error) {
+	cmd := exec.Command("git", "hash-object", "-t", "tree", "/dev/null")
+	cmd.Dir = prj
+	out, err := cmd.CombinedOutput()
+	result := strings.Trim(string(out), DefaultTrimCut)
+	if err != nil {
+		return "", fmt.Errorf("failed to: %s$ git hash-object -t tree /dev/null: %s %s", prj, err.Error(), result)
+	}
+	return result, nil
+}
+
+//Fetch fetches the 'origin' remote
+func Fetch(prj string) (err error) {
+	cmd := exec.Command("git", "fetch", "-q", "origin")
+	cmd.Dir = prj
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("failed to: %s$ git fetch: %s %s", prj, err.Error(), strings.Trim(string(out), DefaultTrimCut))
+	}
+	return nil
+}
+
+//RefExists returns true if 'ref' is a valid ref (e.g. "refs/remotes/origin/master")
+func RefExists(prj, ref string) bool {
+	cmd := exec.Command("git", "rev-parse", "--verify", "-q", ref)
+	cmd.Dir = prj
+	return cmd.Run() == nil
+}
+
+//Push pushes 'branch' to the same branch on 'origin', optionally setting it as the upstream, and atomically (if the remote supports it).
+func Push(prj, branch string, setUpstream, atomic bool) (result string, err error) {
+	args := []string{"push", "--porcelain"}
+	if setUpstream {
+		args = append(args, "--set-upstream")
+	}
+	if atomic {
+		args = append(args, "--atomic")
+	}
+	args = append(args, "origin", branch)
+	cmd := exec.Command("git", args...)
+	cmd.Dir = prj
+	cmd.Env = append(os.Environ(), "LC_ALL=C") // the missing atomic support is only reported as a message
+	out, err := cmd.CombinedOutput()
+	result = strings.Trim(string(out), DefaultTrimCut)
+	if err != nil {
+		if atomic && strings.Contains(result, "atomic") && strings.Contains(result, "support") {
+			return Push(prj, branch, setUpstream, false) // the remote does not support atomic pushes
+		}
+		return result, fmt.Errorf("failed to: %s$ git %s: %s %s", prj, strings.Join(args, " "), err.Error(), result)
+	}
+	return result, nil
+}
+
+//Rebase rebases the current branch onto 'upstream', stashing local changes before, and applying them after.
+//
+// If the rebase fails, it is aborted: the repository is left as it was, and conflicts are returned as a *ConflictError.
+// 'stashed' is true when local changes could not be applied back: they are kept in the stash.
+func Rebase(prj, upstream string) (stashed bool, err error) {
+	before, _ := RevParse(prj, "refs/stash")
+	cmd := exec.Command("git", "rebase", "--autostash", upstream)
+	cmd.Dir = prj
+	out, err := cmd.CombinedOutput()
+	result := strings.Trim(string(out), DefaultTrimCut)
+	if err != nil {
+		unmerged, _ := Unmerged(prj) // before the abort resets them
+		abort := exec.Command("git", "rebase", "--abort")
+		abort.Dir = prj
+		if out, aerr := abort.CombinedOutput(); aerr != nil {
+			return false, fmt.Errorf("failed to: %s$ git rebase: %s %s, and cannot abort: %s %s", prj, err.Error(), result, aerr.Error(), string(out))
+		}
+		if len(unmerged) > 0 {
+			return false, &ConflictError{Files: unmerged}
+		}
+		return false, fmt.Errorf("failed to: %s$ git rebase: %s %s", prj, err.Error(), result)
+	}
+	after, _ := RevParse(prj, "refs/stash")
+	return after != before, nil
+}
+
+//StashPush stashes local changes, including untracked files, with the message 'msg'.
+//
+// It returns the stash commit sha1, or an empty string if there was nothing to stash.
+func StashPush(prj, msg string) (sha string, err error) {
+	before, _ := RevParse(prj, "refs/stash")
+	cmd := exec.Command("git", "stash", "push", "-u", "-m", msg)
+	cmd.Dir = prj
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		return "", fmt.Errorf("failed to: %s$ git stash push: %s %s", prj, err.Error(), strings.Trim(string(out), DefaultTrimCut))
+	}
+	after, _ := RevParse(prj, "refs/stash")
+	if after == before {
+		return "", nil
+	}
+	return after, nil
+}
+
+//StashList returns the sha1 of all stash entries, the most recent first.
+func StashList(prj string) (shas []string, err error) {
+	cmd := exec.Command("git", "stash", "list", "--format=%H")
+	cmd.Dir = prj
+	out, err := cmd.CombinedOutput()
+	result := strings.Trim(string(out), DefaultTrimCut)
+	if err != nil {
+		return nil, fmt.Errorf("failed to: %s$ git stash list: %s %s", prj, err.Error(), result)
+	}
+	if result == "" {
+		return nil, nil
+	}
+	return strings.Split(result, "\n"), nil
+}
+
+//StashPop applies and removes the stash entry 'sha'. If it conflicts, the entry is kept.
+func StashPop(prj, sha string) (result string, err error) { return stash(prj, "pop", sha) }
+
+//StashDrop removes the stash entry 'sha' (if it still exists).
+func StashDrop(prj, sha string) (result string, err error) { return stash(prj, "drop", sha) }
+
+//stash runs a git stash command on the entry 'sha'.
+func stash(prj, command, sha string) (result string, err error) {
+	shas, err := StashList(prj)
+	if err != nil {
+		return "", err
+	}
+	for i, s := range shas {
+		if s == sha {
+			cmd := exec.Command("git", "stash", command, fmt.Sprintf("stash@{%d}", i))
+			cmd.Dir = prj
+			out, err := cmd.CombinedOutput()
+			result = strings.Trim(string(out), DefaultTrimCut)
+			if err != nil {
+				if unmerged, _ := Unmerged(prj); len(unmerged) > 0 {
+					return result, &ConflictError{Files: unmerged}
+				}
+				return result, fmt.Errorf("failed to: %s$ git stash %s: %s %s", prj, command, err.Error(), result)
+			}
+			return result, nil
+		}
+	}
+	if command == "drop" { // already dropped
+		return "", nil
+	}
+	return "", fmt.Errorf("%s: no stash entry %s", prj, sha)
+}
+
+//RevParse returns the sha1 of 'rev'
+func RevParse(prj, rev string) (sha string, err error) {
+	cmd := exec.Command("git", "rev-parse", "--verify", "-q", rev)
+	cmd.Dir = prj
+	out, err := cmd.CombinedOutput()
+	result := strings.Trim(string(out), DefaultTrimCut)
+	if err != nil {
+		return "", fmt.Errorf("failed to: %s$ git rev-parse %s: %s %s", prj, rev, err.Error(), result)
+	}
+	return result, nil
+}
+
+//RevListBefore returns the last commit of 'rev' first-parent history, committed before 'date' (any date git understands).
+func RevListBefore(prj, rev, date string) (sha string, err error) {
+	cmd := exec.Command("git", "rev-list", "-1", "--first-parent", "--before="+date, rev)
+	cmd.Dir = prj
+	out, err := cmd.CombinedOutput()
+	result := strings.Trim(string(out), DefaultTrimCut)
+	if err != nil {
+		return "", fmt.Errorf("failed to: %s$ git rev-list --before=%q %s: %s %s", prj, date, rev, err.Error(), result)
+	}
+	if result == "" {
+		return "", fmt.Errorf("%s: no commit in %s before %q", prj, rev, date)
+	}
+	return result, nil
+}
+
+//CheckoutDetach checks out 'rev' in detached HEAD mode
+func CheckoutDetach(prj, rev string) (err error) {
+	cmd := exec.Command("git", "checkout", "-q", "--detach", rev)
+	cmd.Dir = prj
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("%s: %v", strings.Trim(string(out), DefaultTrimCut), err)
+	}
+	return nil
+}
+
+//TagsMerged returns the tags reachable from HEAD, the most recent first, and their creation date (unix time).
+func TagsMerged(prj string) (tags []string, dates []int64, err error) {
+	cmd := exec.Command("git", "for-each-ref", "--merged", "HEAD", "--sort=-creatordate", "--format=%(creatordate:unix) %(refname:strip=2)", "refs/tags")
+	cmd.Dir = prj
+	out, err := cmd.CombinedOutput()
+	result := strings.Trim(string(out), DefaultTrimCut)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to: %s$ git for-each-ref --merged HEAD refs/tags: %s %s", prj, err.Error(), result)
+	}
+	if result == "" {
+		return nil, nil, nil
+	}
+	for _, line := range strings.Split(result, "\n") {
+		split := strings.SplitN(line, " ", 2)
+		if len(split) != 2 {
+			return nil, nil, fmt.Errorf("parsing error: %s$ git for-each-ref: invalid line %q", prj, line)
+		}
+		date, err := strconv.ParseInt(split[0], 10, 64)
+		if err != nil {
+			return nil, nil, fmt.Errorf("parsing error: %s$ git for-each-ref: invalid date %q: %v", prj, split[0], err)
+		}
+		tags, dates = append(tags, split[1]), append(dates, date)
+	}
+	return tags, dates, nil
+}
+
+//Changed returns the tracked files that differ between HEAD and the working tree, sorted.
+//
+// Only names are read, so that the result does not depend on the diff configuration.
+func Changed(prj string) (files []string, err error) {
+	cmd := exec.Command("git", "diff", "-z", "--name-only", "--no-renames", "--no-relative", "HEAD")
+	cmd.Dir = prj
+	stderr := new(bytes.Buffer)
+	cmd.Stderr = stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to: %s$ git diff --name-only HEAD: %s %s", prj, err.Error(), strings.Trim(stderr.String(), DefaultTrimCut))
+	}
+	for _, f := range strings.Split(string(out), "\x00") {
+		if f != "" {
+			files = append(files, f)
+		}
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+//Untracked returns the untracked files (not ignored), sorted
+func Untracked(prj string) (files []string, err error) {
+	cmd := exec.Command("git", "ls-files", "-z", "--others", "--exclude-standard")
+	cmd.Dir = prj
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		return nil, fmt.Errorf("failed to: %s$ git ls-files --others: %s %s", prj, err.Error(), strings.Trim(string(out), DefaultTrimCut))
+	}
+	for _, f := range strings.Split(string(out), "\x00") {
+		if f != "" {
+			files = append(files, f)
+		}
+	}
+	sort.Strings(files)
+	return files, nil
+}
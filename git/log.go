@@ -0,0 +1,86 @@
+package git
+
+import (
+	"bytes"
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//Commit is a commit read from git log.
+type Commit struct {
+	Sha        string
+	Author     string
+	Email      string
+	Date       time.Time // author date
+	CommitDate time.Time // committer date
+	Subject    string
+	Body       string
+	Stat       string // diff stat, only if asked for
+}
+
+// record separators for the log format, they cannot appear in commit messages
+const (
+	logRecord = "\x1e"
+	logField  = "\x1f"
+	logEnd    = "\x1d"
+)
+
+//Log runs git log with 'args' (any options or revision range) and parses the commits.
+//
+// a '--stat' option fills the Commit.Stat field.
+func Log(prj string, args ...string) (commits []Commit, err error) {
+	format := "--format=" + logRecord + strings.Join([]string{"%H", "%an", "%ae", "%at", "%ct", "%s", "%b"}, logField) + logEnd
+	cmd := exec.Command("git", append([]string{"log", format}, args...)...)
+	cmd.Dir = prj
+	stderr := new(bytes.Buffer)
+	cmd.Stderr = stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("execution error: %s$ git log %s -> error %v: %s", prj, strings.Join(args, " "), err, strings.Trim(stderr.String(), DefaultTrimCut))
+	}
+	return parseLog(string(out))
+}
+
+//parseLog parses the output of git log, in the format used by Log.
+func parseLog(out string) (commits []Commit, err error) {
+	records := strings.Split(out, logRecord)
+	commits = make([]Commit, 0, len(records))
+	for _, rec := range records[1:] { // first one is always empty
+		end := strings.Index(rec, logEnd)
+		if end < 0 {
+			return commits, fmt.Errorf("invalid git log record %q", rec)
+		}
+		fields := strings.SplitN(rec[:end], logField, 7)
+		if len(fields) != 7 {
+			return commits, fmt.Errorf("invalid git log record %q", rec)
+		}
+		c := Commit{
+			Sha:     fields[0],
+			Author:  fields[1],
+			Email:   fields[2],
+			Subject: fields[5],
+			Body:    strings.Trim(fields[6], DefaultTrimCut),
+			Stat:    strings.Trim(rec[end+1:], "\n"),
+		}
+		if c.Date, err = unix(fields[3]); err != nil {
+			return commits, err
+		}
+		if c.CommitDate, err = unix(fields[4]); err != nil {
+			return commits, err
+		}
+		commits = append(commits, c)
+	}
+	return commits, nil
+}
+
+//unix parses a unix timestamp.
+func unix(s string) (time.Time, error) {
+	sec, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid timestamp %q: %v", s, err)
+	}
+	return time.Unix(sec, 0), nil
+}
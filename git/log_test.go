@@ -0,0 +1,44 @@
+package git
+
+import "testing"
+
+func TestParseLog(t *testing.T) {
+
+	rec := func(fields ...string) string {
+		s := logRecord
+		for i, f := range fields {
+			if i > 0 {
+				s += logField
+			}
+			s += f
+		}
+		return s + logEnd
+	}
+	out := rec("s1", "Ann", "ann@x", "100", "200", "fix it", "details\n\n") + "\n" +
+		rec("s2", "Bob", "bob@x", "300", "300", "add it", "") + "\n 1 file changed\n"
+
+	commits, err := parseLog(out)
+	if err != nil {
+		t.Fatalf("cannot parse log: %v", err)
+	}
+	if len(commits) != 2 {
+		t.Fatalf("invalid commits %v", commits)
+	}
+	c := commits[0]
+	if c.Sha != "s1" || c.Author != "Ann" || c.Email != "ann@x" || c.Subject != "fix it" || c.Body != "details" || c.Stat != "" {
+		t.Errorf("invalid first commit %+v", c)
+	}
+	if c.Date.Unix() != 100 || c.CommitDate.Unix() != 200 {
+		t.Errorf("invalid dates %v %v", c.Date, c.CommitDate)
+	}
+	if c := commits[1]; c.Sha != "s2" || c.Body != "" || c.Stat != " 1 file changed" {
+		t.Errorf("invalid second commit %+v", c)
+	}
+
+	if _, err := parseLog(rec("s1", "Ann")); err == nil {
+		t.Errorf("a truncated record should fail")
+	}
+	if _, err := parseLog(rec("s1", "Ann", "ann@x", "x", "200", "fix it", "")); err == nil {
+		t.Errorf("an invalid date should fail")
+	}
+}
@@ -39,7 +39,7 @@ func Patch(d *Sub, delta Delta) (changed bool, err error) {
 // checks optimistic lock (from must be equal to actual)
 // update changed, and err pointer accordingly
 func fpatcher(actual *string, from, to string, changed *bool, err *error) {
-	if err != nil || from == to || *err != nil {
+	if from == to || *err != nil {
 		return
 	}
 	if actual == nil {
@@ -0,0 +1,164 @@
+package sbr
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/ericaro/sbr/git"
+)
+
+//this file contains functions to check out the workspace as it was at a given date.
+//
+// Those states are recorded as manifests in the workspace state (see Workspace.StateDir), so that they can be checked
+// out again later, even when the date was relative ("last tuesday").
+
+//ManifestAt pins every declared subrepository to the last commit, before 'date', in the first-parent history of its
+// remote branch ("origin/<branch>", fetched first, missing subrepositories are cloned). The workspace itself is pinned
+// to its current HEAD.
+func (x *Workspace) ManifestAt(date string) (m Manifest, err error) {
+	subs, err := x.Read()
+	if err != nil {
+		return nil, err
+	}
+	branch, _ := git.Branch(x.wd)
+	remote, _ := git.RemoteOrigin(x.wd)
+	sha, err := git.RevParseHead(x.wd)
+	if err != nil {
+		return nil, err
+	}
+	m = Manifest{Pin{New(".", remote, branch), sha}}
+
+	var errs []string
+	var mu sync.Mutex
+	var waiter sync.WaitGroup
+	for _, s := range subs {
+		waiter.Add(1)
+		go func(s Sub) {
+			defer waiter.Done()
+			prj := filepath.Join(x.wd, s.rel)
+			var sha string
+			var err error
+			if !fileExists(prj) {
+				var res string
+				if res, err = git.Clone(x.wd, s.rel, s.remote, s.branch); err != nil {
+					err = fmt.Errorf("%v %s", err, res)
+				}
+			} else {
+				err = git.Fetch(prj)
+			}
+			if err == nil {
+				sha, err = git.RevListBefore(prj, "origin/"+s.branch, date)
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				errs = append(errs, fmt.Sprintf("cannot pin %s: %v", s.rel, err))
+				return
+			}
+			m = append(m, Pin{s, sha})
+		}(s)
+	}
+	waiter.Wait()
+	if len(errs) > 0 {
+		sort.Strings(errs)
+		return nil, fmt.Errorf("%s", strings.Join(errs, "\n"))
+	}
+	sort.Sort(byPinRel(m))
+	return m, nil
+}
+
+//AtState returns the file where the manifest of the workspace at 'date' is recorded (the date is escaped, so that two
+// dates never share a file).
+func (x *Workspace) AtState(date string) string { return x.StateDir("at", url.QueryEscape(date)) }
+
+//CheckoutAt checks out every declared subrepository as it was at 'date' (see Workspace.ManifestAt), and records the
+// manifest (see Workspace.AtState).
+func (ch *Checkouter) CheckoutAt(date string) (m Manifest, err error) {
+	m, err = ch.wk.ManifestAt(date)
+	if err != nil {
+		return nil, err
+	}
+	filename := ch.wk.AtState(date)
+	if err = os.MkdirAll(filepath.Dir(filename), 0755); err != nil {
+		return nil, err
+	}
+	f, err := os.Create(filename)
+	if err != nil {
+		return nil, err
+	}
+	if _, err = m.WriteTo(f); err != nil {
+		f.Close()
+		return nil, err
+	}
+	if err = f.Close(); err != nil {
+		return nil, err
+	}
+	return m, ch.CheckoutManifest(m)
+}
+
+//CheckoutManifest checks out every subrepository in 'm' at its revision, in detached HEAD mode (missing ones are cloned
+// first). The workspace itself is left as is.
+//
+// 'Checkout' switches them back to their declared branch.
+func (ch *Checkouter) CheckoutManifest(m Manifest) (err error) {
+	var errs []error
+	var mu sync.Mutex
+	var waiter sync.WaitGroup
+	for _, p := range m {
+		if p.rel == "." {
+			continue
+		}
+		waiter.Add(1)
+		go func(p Pin) {
+			defer waiter.Done()
+			prj := ch.locate(p.rel)
+			var res string
+			var err error
+			if !fileExists(prj) {
+				res, err = git.Clone(ch.wk.Wd(), p.rel, p.remote, p.branch)
+			} else if _, e := git.RevParse(prj, p.Rev+"^{commit}"); e != nil {
+				err = git.Fetch(prj) // the revision is not known yet
+			}
+			if err == nil {
+				err = git.CheckoutDetach(prj, p.Rev)
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				fmt.Fprintf(ch.w, "ERR  Checking out '%s' at %s   : %s\n%s\n", p.rel, p.Rev, err.Error(), res)
+				errs = append(errs, err)
+				return
+			}
+			fmt.Fprintf(ch.w, "     Checking out '%s' at %s\n", p.rel, p.Rev)
+		}(p)
+	}
+	waiter.Wait()
+	if len(errs) > 0 {
+		return fmt.Errorf("Errors occured (%v) during operations", len(errs))
+	}
+	return nil
+}
+
+//CheckoutBranches switches every declared subrepository back to its declared branch (without pulling).
+func (ch *Checkouter) CheckoutBranches() (err error) {
+	subs, err := ch.wk.Read()
+	if err != nil {
+		return err
+	}
+	var errs []error
+	for _, s := range subs {
+		if _, err := ch.UpdateBranch(Delta{Old: s, New: s}); err != nil { // the actual branch is read from the disk
+			fmt.Fprintf(ch.w, "ERR  Switching '%s' back to %s   : %s\n", s.rel, s.branch, err.Error())
+			errs = append(errs, err)
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("Errors occured (%v) during operations", len(errs))
+	}
+	return nil
+}
@@ -0,0 +1,240 @@
+package sbr
+
+import (
+	"encoding/csv"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/ericaro/sbr/git"
+)
+
+//Change is a commit in a subrepository: a step from one workspace state to another.
+type Change struct {
+	Rel     string
+	Sha     string
+	Date    time.Time // commit date
+	Subject string
+}
+
+//Bisect is a binary search of the change that broke the workspace, between a good and a bad workspace state.
+//
+// Changes between the two states are ordered, and the state 'i' is the good state with the first 'i' changes applied:
+// state 0 is the good one, state len(Changes) is the bad one.
+//
+// A Bisect is kept in the workspace state (see Workspace.StateDir) in two files: 'base', the good state as a Manifest,
+// and 'changes', a record lo hi current followed by a record per change: path sha date subject.
+type Bisect struct {
+	Base    Manifest // the good state
+	Changes []Change
+	Lo, Hi  int // the last state known to be good, and the first known to be bad
+	Current int // the state being tested
+}
+
+//NewBisect starts a bisect of 'changes' applied to 'base', testing the middle state.
+func NewBisect(base Manifest, changes []Change) *Bisect {
+	b := &Bisect{Base: base, Changes: changes, Hi: len(changes)}
+	b.Current = b.Next()
+	return b
+}
+
+//Done returns true when the first bad change is known.
+func (b *Bisect) Done() bool { return b.Hi-b.Lo <= 1 }
+
+//Next returns the state to be tested next.
+func (b *Bisect) Next() int { return (b.Lo + b.Hi) / 2 }
+
+//Steps returns an estimate of the number of states left to test.
+func (b *Bisect) Steps() int {
+	if b.Done() {
+		return 0
+	}
+	return int(math.Ceil(math.Log2(float64(b.Hi - b.Lo))))
+}
+
+//Mark records the result of the test of the current state, and moves to the next state to test.
+func (b *Bisect) Mark(good bool) {
+	if good && b.Current > b.Lo {
+		b.Lo = b.Current
+	}
+	if !good && b.Current < b.Hi {
+		b.Hi = b.Current
+	}
+	b.Current = b.Next()
+}
+
+//Culprit returns the first bad change, once the bisect is done.
+func (b *Bisect) Culprit() (c Change, found bool) {
+	if !b.Done() || b.Hi == 0 {
+		return c, false
+	}
+	return b.Changes[b.Hi-1], true
+}
+
+//State returns the workspace state 'i': the base with the first 'i' changes applied.
+func (b *Bisect) State(i int) Manifest {
+	m := make(Manifest, len(b.Base))
+	copy(m, b.Base)
+	index := make(map[string]int, len(m))
+	for j, p := range m {
+		index[p.rel] = j
+	}
+	for _, c := range b.Changes[:i] {
+		if j, exists := index[c.Rel]; exists {
+			m[j].Rev = c.Sha
+		}
+	}
+	return m
+}
+
+//Changes lists the commits (first-parent history) of every subrepository between two workspace states, ordered by
+// date, but keeping the order of each subrepository.
+//
+// The workspace itself, and subrepositories that are not in both states are ignored (see Unpaired).
+func (x *Workspace) Changes(from, to Manifest) (changes []Change, err error) {
+	all := make([][]Change, 0, len(to))
+	for _, p := range to {
+		old, exists := from.Rev(p.rel)
+		if p.rel == "." || !exists || old == p.Rev {
+			continue
+		}
+		commits, err := git.Log(filepath.Join(x.wd, p.rel), "--first-parent", "--reverse", old+".."+p.Rev)
+		if err != nil {
+			return nil, err
+		}
+		repo := make([]Change, 0, len(commits))
+		for _, c := range commits {
+			repo = append(repo, Change{Rel: p.rel, Sha: c.Sha, Date: c.CommitDate, Subject: c.Subject})
+		}
+		all = append(all, repo)
+	}
+	return mergeChanges(all), nil
+}
+
+//Unpaired returns subrepositories that are only in the 'to' state ('added'), and only in the 'from' state
+// ('removed'). Their changes are not bisected.
+func Unpaired(from, to Manifest) (added, removed []string) {
+	for _, p := range to {
+		if _, exists := from.Rev(p.rel); !exists {
+			added = append(added, p.rel)
+		}
+	}
+	for _, p := range from {
+		if _, exists := to.Rev(p.rel); !exists {
+			removed = append(removed, p.rel)
+		}
+	}
+	return added, removed
+}
+
+//mergeChanges merges changes of each subrepository, the earliest first, keeping the order of each subrepository.
+func mergeChanges(all [][]Change) []Change {
+	merged := make([]Change, 0, 100)
+	for {
+		next := -1
+		for i, repo := range all {
+			if len(repo) > 0 && (next < 0 || repo[0].Date.Before(all[next][0].Date)) {
+				next = i
+			}
+		}
+		if next < 0 {
+			return merged
+		}
+		merged = append(merged, all[next][0])
+		all[next] = all[next][1:]
+	}
+}
+
+//ReadBisect reads the bisect in progress, nil if there is none.
+func (x *Workspace) ReadBisect() (b *Bisect, err error) {
+	base, err := ReadManifestFile(x.StateDir("bisect", "base"))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(x.StateDir("bisect", "changes"))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	r := csv.NewReader(f)
+	r.Comma = ' '
+	r.FieldsPerRecord = -1
+	records, err := r.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	if len(records) == 0 || len(records[0]) != 3 {
+		return nil, fmt.Errorf("invalid bisect state: missing the lo hi current record")
+	}
+	b = &Bisect{Base: base}
+	for i, p := range []*int{&b.Lo, &b.Hi, &b.Current} {
+		if *p, err = strconv.Atoi(records[0][i]); err != nil {
+			return nil, fmt.Errorf("invalid bisect state: %v", err)
+		}
+	}
+	for i, record := range records[1:] {
+		if len(record) != 4 {
+			return nil, fmt.Errorf("invalid %vth bisect change #fields must be 4 not %v", i, len(record))
+		}
+		date, err := time.Parse(time.RFC3339, record[2])
+		if err != nil {
+			return nil, fmt.Errorf("invalid bisect change date %q: %v", record[2], err)
+		}
+		b.Changes = append(b.Changes, Change{Rel: record[0], Sha: record[1], Date: date, Subject: record[3]})
+	}
+	if b.Lo < 0 || b.Hi > len(b.Changes) || b.Lo > b.Hi {
+		return nil, fmt.Errorf("invalid bisect state: %v..%v for %v changes", b.Lo, b.Hi, len(b.Changes))
+	}
+	return b, nil
+}
+
+//WriteBisect writes the bisect in progress.
+func (x *Workspace) WriteBisect(b *Bisect) (err error) {
+	if err = os.MkdirAll(x.StateDir("bisect"), 0755); err != nil {
+		return err
+	}
+	f, err := os.Create(x.StateDir("bisect", "base"))
+	if err != nil {
+		return err
+	}
+	if _, err = b.Base.WriteTo(f); err != nil {
+		f.Close()
+		return err
+	}
+	if err = f.Close(); err != nil {
+		return err
+	}
+
+	f, err = os.Create(x.StateDir("bisect", "changes"))
+	if err != nil {
+		return err
+	}
+	w := csv.NewWriter(f) // subjects may contain quotes
+	w.Comma = ' '
+	err = w.Write([]string{strconv.Itoa(b.Lo), strconv.Itoa(b.Hi), strconv.Itoa(b.Current)})
+	for _, c := range b.Changes {
+		if err != nil {
+			break
+		}
+		err = w.Write([]string{c.Rel, c.Sha, c.Date.Format(time.RFC3339), c.Subject})
+	}
+	w.Flush()
+	if err == nil {
+		err = w.Error()
+	}
+	if err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+//ResetBisect removes the bisect in progress.
+func (x *Workspace) ResetBisect() error { return os.RemoveAll(x.StateDir("bisect")) }
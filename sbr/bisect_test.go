@@ -0,0 +1,54 @@
+package sbr
+
+import (
+	"testing"
+	"time"
+)
+
+func TestMergeChanges(t *testing.T) {
+	day := func(d int) time.Time { return time.Date(2015, 3, d, 12, 0, 0, 0, time.UTC) }
+	a := []Change{{Rel: "a", Sha: "a1", Date: day(1)}, {Rel: "a", Sha: "a2", Date: day(5)}, {Rel: "a", Sha: "a3", Date: day(4)}}
+	b := []Change{{Rel: "b", Sha: "b1", Date: day(2)}, {Rel: "b", Sha: "b2", Date: day(6)}}
+
+	merged := mergeChanges([][]Change{a, b})
+	x := []string{"a1", "b1", "a2", "a3", "b2"} // a3 is older than a2, but comes after it in 'a'
+	if len(merged) != len(x) {
+		t.Fatalf("invalid merged changes %v", merged)
+	}
+	for i, c := range merged {
+		if c.Sha != x[i] {
+			t.Errorf("invalid %vth change %q expecting %q", i, c.Sha, x[i])
+		}
+	}
+}
+
+func TestBisect(t *testing.T) {
+	base := Manifest{{New("a", "ra", "master"), "a0"}, {New("b", "rb", "master"), "b0"}}
+	changes := []Change{{Rel: "a", Sha: "a1"}, {Rel: "b", Sha: "b1"}, {Rel: "a", Sha: "a2"}, {Rel: "b", Sha: "b2"}, {Rel: "a", Sha: "a3"}}
+
+	if m := NewBisect(base, changes).State(3); m[0].Rev != "a2" || m[1].Rev != "b1" {
+		t.Errorf("invalid state 3: %v", m)
+	}
+
+	for culprit := range changes {
+		b := NewBisect(base, changes)
+		for i := 0; !b.Done(); i++ {
+			if i > len(changes) {
+				t.Fatalf("bisect does not end for culprit %v", culprit)
+			}
+			b.Mark(b.Current <= culprit) // state i contains changes[:i]
+		}
+		if c, found := b.Culprit(); !found || c.Sha != changes[culprit].Sha {
+			t.Errorf("invalid culprit %v, expecting %v", c, changes[culprit])
+		}
+	}
+}
+
+func TestUnpaired(t *testing.T) {
+	from := Manifest{{New("a", "ra", "master"), "a0"}, {New("b", "rb", "master"), "b0"}}
+	to := Manifest{{New("b", "rb", "master"), "b1"}, {New("c", "rc", "master"), "c1"}}
+	added, removed := Unpaired(from, to)
+	if len(added) != 1 || added[0] != "c" || len(removed) != 1 || removed[0] != "a" {
+		t.Errorf("invalid unpaired %v %v", added, removed)
+	}
+}
@@ -0,0 +1,211 @@
+package sbr
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/ericaro/sbr/git"
+)
+
+//this file contains Checkouter methods to manage feature branches across subrepositories.
+//
+// The declarations of subrepositories, before they were moved to a feature branch, are kept in the workspace state
+// (see Workspace.StateDir) in the '.sbr' format, to switch them back when the feature is finished.
+
+//branchState returns the file that keeps the original declarations of subrepositories in feature branch 'name'.
+//
+// the name is escaped, so that "feature/x" is a single file.
+func (ch *Checkouter) branchState(name string) string {
+	return ch.wk.StateDir("branch", url.PathEscape(name))
+}
+
+//StartBranch creates the branch 'name' in the subrepositories 'rels' from their declared branch, checks it out,
+// and declares it in '.sbr'.
+//
+// Subrepositories must be declared in '.sbr'. Subrepositories already in the branch are ignored, and existing
+// branches are checked out as is.
+func (ch *Checkouter) StartBranch(name string, rels ...string) (err error) {
+	subs, err := ch.wk.Read()
+	if err != nil {
+		return err
+	}
+	index := indexSbr(subs)
+	original, err := ch.readBranchState(name)
+	if err != nil {
+		return err
+	}
+	started := indexSbr(original)
+
+	upd := make([]Delta, 0, len(rels))
+	for _, rel := range rels {
+		s, exists := index[rel]
+		if !exists {
+			return fmt.Errorf("%s is not declared in '.sbr'", rel)
+		}
+		if s.branch == name {
+			continue
+		}
+		upd = append(upd, Delta{Old: *s, New: New(s.rel, s.remote, name)})
+	}
+
+	var errs []error
+	for _, delta := range upd {
+		path := ch.locate(delta.Rel())
+		if exists, _ := git.BranchExists(path, name); exists { // resume it
+			if err := git.Checkout(path, name, false); err != nil {
+				fmt.Fprintf(ch.w, "ERR  Branching '%s'   : %s\n", delta.Rel(), err.Error())
+				errs = append(errs, err)
+				continue
+			}
+			fmt.Fprintf(ch.w, "     Branching '%s' (existing branch)\n", delta.Rel())
+		} else {
+			start := delta.Old.branch
+			if exists, _ := git.BranchExists(path, start); !exists {
+				start = "origin/" + start
+			}
+			if err := git.CheckoutFrom(path, name, start); err != nil {
+				fmt.Fprintf(ch.w, "ERR  Branching '%s'   : %s\n", delta.Rel(), err.Error())
+				errs = append(errs, err)
+				continue
+			}
+			fmt.Fprintf(ch.w, "     Branching '%s' from %s\n", delta.Rel(), start)
+		}
+		if _, exists := started[delta.Rel()]; !exists {
+			original = append(original, delta.Old)
+		}
+		UpdateAll(subs, delta)
+	}
+
+	if err := ch.writeBranchState(name, original); err != nil {
+		return err
+	}
+	if err := ch.wk.Write(subs); err != nil {
+		return err
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("Errors occured (%v) during operations", len(errs))
+	}
+	return nil
+}
+
+//FinishBranch switches back all subrepositories declared in the branch 'name' to their original branch, and declares it
+// in '.sbr'.
+//
+// Subrepositories whose original branch is unknown (the branch was started elsewhere) are switched to 'base', if not empty.
+func (ch *Checkouter) FinishBranch(name, base string) (err error) {
+	subs, err := ch.wk.Read()
+	if err != nil {
+		return err
+	}
+	original, err := ch.readBranchState(name)
+	if err != nil {
+		return err
+	}
+	started := indexSbr(original)
+
+	var errs []error
+	found := false
+	remaining := make([]Sub, 0, len(original))
+	for _, s := range subs {
+		if s.branch != name {
+			continue
+		}
+		found = true
+		back := base
+		if o, exists := started[s.rel]; exists {
+			back = o.branch
+		}
+		if back == "" {
+			fmt.Fprintf(ch.w, "ERR  Finishing '%s'   : original branch is unknown\n", s.rel)
+			errs = append(errs, fmt.Errorf("%s: unknown original branch", s.rel))
+			continue
+		}
+		delta := Delta{Old: s, New: New(s.rel, s.remote, back)}
+		if _, err := ch.UpdateBranch(delta); err != nil {
+			fmt.Fprintf(ch.w, "ERR  Finishing '%s'   : %s\n", s.rel, err.Error())
+			errs = append(errs, err)
+			if o, exists := started[s.rel]; exists {
+				remaining = append(remaining, *o)
+			}
+			continue
+		}
+		fmt.Fprintf(ch.w, "     Finishing '%s' back to %s\n", s.rel, back)
+		UpdateAll(subs, delta)
+	}
+
+	if !found {
+		return fmt.Errorf("no subrepository is declared in branch %q", name)
+	}
+	if err := ch.writeBranchState(name, remaining); err != nil {
+		return err
+	}
+	if err := ch.wk.Write(subs); err != nil {
+		return err
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("Errors occured (%v) during operations", len(errs))
+	}
+	return nil
+}
+
+//Branches returns the names of feature branches started in this workspace.
+func (ch *Checkouter) Branches() (names []string, err error) {
+	f, err := os.Open(ch.wk.StateDir("branch"))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	files, err := f.Readdirnames(-1)
+	if err != nil {
+		return nil, err
+	}
+	for _, file := range files {
+		name, err := url.PathUnescape(file)
+		if err != nil {
+			return nil, fmt.Errorf("invalid branch state %q: %v", file, err)
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+//readBranchState reads the original declarations of subrepositories in branch 'name' (none if the branch was not started here).
+func (ch *Checkouter) readBranchState(name string) (subs []Sub, err error) {
+	f, err := os.Open(ch.branchState(name))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ReadFrom(f)
+}
+
+//writeBranchState writes the original declarations of subrepositories in branch 'name', or removes it if there are none.
+func (ch *Checkouter) writeBranchState(name string, subs []Sub) (err error) {
+	filename := ch.branchState(name)
+	if len(subs) == 0 {
+		err = os.Remove(filename)
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	if err = os.MkdirAll(filepath.Dir(filename), 0755); err != nil {
+		return err
+	}
+	f, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	WriteTo(f, subs)
+	return f.Close()
+}
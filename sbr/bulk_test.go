@@ -149,3 +149,23 @@ func ExampleReadFrom() {
 
 	//Output: Ok
 }
+
+func TestUpdateAll(t *testing.T) {
+
+	s1 := New("1", "r1", "b1")
+	s2 := New("2", "r2", "b2")
+	subs := []Sub{s1, s2}
+
+	changed, err := UpdateAll(subs, Delta{s2, New("2", "r2", "feature")})
+	if err != nil || !changed {
+		t.Fatalf("update should succeed: %v %v", changed, err)
+	}
+	if x := []Sub{s1, New("2", "r2", "feature")}; !Equals(subs, x) {
+		t.Errorf("should be equals: %v vs %v", subs, x)
+	}
+
+	// optimistic lock: old values must match
+	if _, err := UpdateAll(subs, Delta{s2, New("2", "r2", "other")}); err == nil {
+		t.Errorf("update of a changed subrepository should fail")
+	}
+}
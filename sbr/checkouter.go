@@ -67,7 +67,13 @@ func (ch *Checkouter) Checkout() (digest []byte, err error) {
 		fmt.Fprintf(ch.w, "ERR  Getting Version %q\n", err.Error())
 		refresherrors = append(refresherrors, err)
 	}
-	fmt.Fprintf(ch.w, "Workspace Version %x\n", v)
+	// v is the legacy (scan) version, still returned for the ci, but the declared one is printed as in 'sbr version'
+	if dv, _, err := ch.wk.DeclaredVersion(false); err != nil {
+		fmt.Fprintf(ch.w, "ERR  Getting Version %q\n", err.Error())
+		refresherrors = append(refresherrors, err)
+	} else {
+		fmt.Fprintf(ch.w, "Workspace Version %s%x\n", VersionPrefix, dv)
+	}
 	if len(refresherrors) > 0 {
 		//TODO(EA) if len(errors) not too big print them out too
 		return v, fmt.Errorf("Errors occured (%v) during operations", len(refresherrors))
@@ -257,7 +263,8 @@ func (ch *Checkouter) UpdateBranch(delta Delta) (updated bool, err error) {
 	if err != nil {
 		return false, err
 	}
-	err = git.Checkout(path, branch, !exists)
+	// a missing branch tracks the remote one if any (git checkout creates it), or starts from HEAD
+	err = git.Checkout(path, branch, !exists && !git.RefExists(path, "refs/remotes/origin/"+branch))
 	if err != nil {
 		return false, err
 	}
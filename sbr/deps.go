@@ -0,0 +1,282 @@
+package sbr
+
+import (
+	"fmt"
+	"go/parser"
+	"go/token"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+//Dependencies describes how subrepositories depend on each other through their Go imports.
+type Dependencies struct {
+	Subs    []string            // rel path of every analysed subrepository (sorted)
+	Graph   map[string][]string // rel path -> rel paths of the subrepositories it imports (sorted)
+	Missing map[string][]string // import path not satisfied by any subrepository -> rel paths importing it (sorted)
+}
+
+//ImportPath returns the Go import path of a subrepository, assuming a GOPATH-like layout:
+// everything after the first 'src' directory.
+//
+// "src/github.com/ericaro/sbr" -> "github.com/ericaro/sbr"
+func ImportPath(rel string) string {
+	elems := strings.Split(filepath.ToSlash(rel), "/")
+	for i, e := range elems {
+		if e == "src" {
+			return strings.Join(elems[i+1:], "/")
+		}
+	}
+	return strings.Join(elems, "/")
+}
+
+//IsStandard returns true if the import path belongs to the standard library
+// (its first element does not contain a dot) or is the special "C" import.
+func IsStandard(imp string) bool {
+	first := strings.SplitN(imp, "/", 2)[0]
+	return !strings.Contains(first, ".")
+}
+
+//ParseImports walks dir and returns all the packages imported by go files (test files included).
+//
+// directories named 'vendor' or 'testdata' and directories starting with '.' or '_' are skipped, as the go tool does.
+// nested repositories are skipped too.
+func ParseImports(dir string) (imports []string, err error) {
+	set := make(map[string]bool)
+	fset := token.NewFileSet()
+
+	walker := func(path string, f os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		name := f.Name()
+		if f.IsDir() {
+			if path != dir && (name == "vendor" || name == "testdata" || strings.HasPrefix(name, ".") || strings.HasPrefix(name, "_")) {
+				return filepath.SkipDir
+			}
+			if path != dir && fileExists(filepath.Join(path, ".git")) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !strings.HasSuffix(name, ".go") {
+			return nil
+		}
+		file, err := parser.ParseFile(fset, path, nil, parser.ImportsOnly)
+		if err != nil {
+			return err
+		}
+		for _, spec := range file.Imports {
+			imp, err := strconv.Unquote(spec.Path.Value)
+			if err != nil {
+				return fmt.Errorf("%s: invalid import %s", path, spec.Path.Value)
+			}
+			set[imp] = true
+		}
+		return nil
+	}
+	err = filepath.Walk(dir, walker)
+
+	imports = make([]string, 0, len(set))
+	for imp := range set {
+		imports = append(imports, imp)
+	}
+	sort.Strings(imports)
+	return
+}
+
+//NewDependencies computes the Dependencies between subs, given the imports of each one (indexed by rel path).
+//
+// 'vendored' reports whether an import is provided by the sub itself (for instance in its vendor directory), it can be nil.
+func NewDependencies(imports map[string][]string, vendored func(rel, imp string) bool) *Dependencies {
+	d := &Dependencies{
+		Subs:    make([]string, 0, len(imports)),
+		Graph:   make(map[string][]string),
+		Missing: make(map[string][]string),
+	}
+	for rel := range imports {
+		d.Subs = append(d.Subs, rel)
+	}
+	sort.Strings(d.Subs)
+
+	for _, rel := range d.Subs {
+		deps := make(map[string]bool)
+		for _, imp := range imports[rel] {
+			if IsStandard(imp) {
+				continue
+			}
+			target, found := d.resolve(imp)
+			switch {
+			case found && target != rel:
+				deps[target] = true
+			case found: // internal import
+			case vendored != nil && vendored(rel, imp):
+			default:
+				d.Missing[imp] = append(d.Missing[imp], rel)
+			}
+		}
+		targets := make([]string, 0, len(deps))
+		for t := range deps {
+			targets = append(targets, t)
+		}
+		sort.Strings(targets)
+		d.Graph[rel] = targets
+	}
+	return d
+}
+
+//resolve find out the sub that provides the import 'imp'.
+//
+// when several subs match (nested repositories) the deepest one wins.
+func (d *Dependencies) resolve(imp string) (rel string, found bool) {
+	var best string
+	for _, sub := range d.Subs {
+		p := ImportPath(sub)
+		if (imp == p || strings.HasPrefix(imp, p+"/")) && len(p) >= len(best) {
+			rel, best, found = sub, p, true
+		}
+	}
+	return
+}
+
+//MissingImports returns the sorted list of import paths that no subrepository provides.
+func (d *Dependencies) MissingImports() []string {
+	res := make([]string, 0, len(d.Missing))
+	for imp := range d.Missing {
+		res = append(res, imp)
+	}
+	sort.Strings(res)
+	return res
+}
+
+//Unused returns the subrepositories that no other subrepository imports.
+func (d *Dependencies) Unused() []string {
+	used := make(map[string]bool)
+	for _, targets := range d.Graph {
+		for _, t := range targets {
+			used[t] = true
+		}
+	}
+	res := make([]string, 0)
+	for _, rel := range d.Subs {
+		if !used[rel] {
+			res = append(res, rel)
+		}
+	}
+	return res
+}
+
+//Levels sorts the subrepositories in topological order: each level only depends on previous ones.
+//
+// subrepositories in the same level are independent, and sorted by path. If there is a dependency cycle an error
+// describing it is returned.
+func (d *Dependencies) Levels() (levels [][]string, err error) {
+	done := make(map[string]bool, len(d.Subs))
+	for len(done) < len(d.Subs) {
+		level := make([]string, 0)
+		for _, rel := range d.Subs {
+			if !done[rel] && d.after(rel, done) {
+				level = append(level, rel)
+			}
+		}
+		if len(level) == 0 {
+			return levels, fmt.Errorf("dependency cycle: %s", strings.Join(d.cycle(done), " -> "))
+		}
+		for _, rel := range level {
+			done[rel] = true
+		}
+		levels = append(levels, level)
+	}
+	return levels, nil
+}
+
+//after returns true if all the dependencies of 'rel' are done.
+func (d *Dependencies) after(rel string, done map[string]bool) bool {
+	for _, t := range d.Graph[rel] {
+		if !done[t] {
+			return false
+		}
+	}
+	return true
+}
+
+//cycle finds a cycle among the subrepositories that are not done.
+//
+// every one of them has at least one dependency not done, so following them always ends up in a loop.
+func (d *Dependencies) cycle(done map[string]bool) []string {
+	var current string
+	for _, rel := range d.Subs {
+		if !done[rel] {
+			current = rel
+			break
+		}
+	}
+	path := make([]string, 0)
+	visited := make(map[string]int)
+	for {
+		if i, loop := visited[current]; loop {
+			return append(path[i:], current)
+		}
+		visited[current] = len(path)
+		path = append(path, current)
+		for _, t := range d.Graph[current] {
+			if !done[t] {
+				current = t
+				break
+			}
+		}
+	}
+}
+
+//WriteGraph writes the dependency graph in a plain text format: one "rel -> dependency" line per edge.
+func (d *Dependencies) WriteGraph(w io.Writer) {
+	for _, rel := range d.Subs {
+		for _, t := range d.Graph[rel] {
+			fmt.Fprintf(w, "%s -> %s\n", rel, t)
+		}
+	}
+}
+
+//WriteDot writes the dependency graph in graphviz's DOT format.
+func (d *Dependencies) WriteDot(w io.Writer) {
+	fmt.Fprintf(w, "digraph sbr {\n")
+	for _, rel := range d.Subs {
+		fmt.Fprintf(w, "\t%q;\n", rel)
+	}
+	for _, rel := range d.Subs {
+		for _, t := range d.Graph[rel] {
+			fmt.Fprintf(w, "\t%q -> %q;\n", rel, t)
+		}
+	}
+	fmt.Fprintf(w, "}\n")
+}
+
+//Dependencies parses the Go imports of every subrepository declared in the .sbr file, and
+// computes their Dependencies.
+//
+// subrepositories declared but missing on the disk are ignored.
+func (x *Workspace) Dependencies() (deps *Dependencies, err error) {
+	subs, err := x.Read()
+	if err != nil {
+		return
+	}
+	imports := make(map[string][]string, len(subs))
+	for _, s := range subs {
+		dir := filepath.Join(x.wd, s.Rel())
+		if !fileExists(dir) {
+			continue
+		}
+		imps, err := ParseImports(dir)
+		if err != nil {
+			return nil, fmt.Errorf("cannot parse imports in %s: %v", s.Rel(), err)
+		}
+		imports[s.Rel()] = imps
+	}
+	vendored := func(rel, imp string) bool {
+		return fileExists(filepath.Join(x.wd, rel, "vendor", filepath.FromSlash(imp)))
+	}
+	return NewDependencies(imports, vendored), nil
+}
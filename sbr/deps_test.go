@@ -0,0 +1,75 @@
+package sbr
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestImportPath(t *testing.T) {
+	for rel, x := range map[string]string{
+		"src/github.com/ericaro/sbr": "github.com/ericaro/sbr",
+		"go/src/github.com/a/b":      "github.com/a/b",
+		"github.com/a/b":             "github.com/a/b",
+	} {
+		if p := ImportPath(rel); p != x {
+			t.Errorf("ImportPath(%q) = %q, should be %q", rel, p, x)
+		}
+	}
+}
+
+func TestNewDependencies(t *testing.T) {
+
+	imports := map[string][]string{
+		"src/a.com/x": {"fmt", "a.com/x/internal", "b.com/y/sub", "c.com/missing"},
+		"src/b.com/y": {"strings", "b.com/y/sub", "v.com/vendored"},
+		"src/c.com/z": {"a.com/x"},
+	}
+	vendored := func(rel, imp string) bool { return rel == "src/b.com/y" && imp == "v.com/vendored" }
+
+	d := NewDependencies(imports, vendored)
+
+	xgraph := map[string][]string{
+		"src/a.com/x": {"src/b.com/y"},
+		"src/b.com/y": {},
+		"src/c.com/z": {"src/a.com/x"},
+	}
+	if !reflect.DeepEqual(d.Graph, xgraph) {
+		t.Errorf("graph should be equals: %v vs %v", d.Graph, xgraph)
+	}
+
+	if m, x := d.MissingImports(), []string{"c.com/missing"}; !reflect.DeepEqual(m, x) {
+		t.Errorf("missing should be equals: %v vs %v", m, x)
+	}
+
+	if u, x := d.Unused(), []string{"src/c.com/z"}; !reflect.DeepEqual(u, x) {
+		t.Errorf("unused should be equals: %v vs %v", u, x)
+	}
+}
+
+func TestLevels(t *testing.T) {
+
+	d := &Dependencies{
+		Subs: []string{"a", "b", "c", "d", "e"},
+		Graph: map[string][]string{
+			"a": {"b", "c"},
+			"b": {"c"},
+			"d": {},
+			"e": {"a", "d"},
+		},
+	}
+	levels, err := d.Levels()
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	x := [][]string{{"c", "d"}, {"b"}, {"a"}, {"e"}}
+	if !reflect.DeepEqual(levels, x) {
+		t.Errorf("levels should be equals: %v vs %v", levels, x)
+	}
+
+	// now add a cycle a -> b -> c -> a
+	d.Graph["c"] = []string{"a"}
+	_, err = d.Levels()
+	if err == nil || err.Error() != "dependency cycle: a -> b -> c -> a" {
+		t.Errorf("cycle should be detected, got %v", err)
+	}
+}
@@ -0,0 +1,90 @@
+package sbr
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/ericaro/sbr/git"
+)
+
+//Description is a human readable workspace version: the most recent tag common to the workspace and all its
+// subrepositories, the number of commits since, and the workspace version.
+type Description struct {
+	Tag     string // empty if no tag is common to all repositories
+	Commits int    // total number of commits since Tag, in all repositories
+	Version []byte // see Workspace.DeclaredVersion, local changes included
+	Dirty   bool   // some declared repositories have local changes
+}
+
+//String formats the description like 'git describe': "sbr-1.0+7-gd1-1a2b3c4-dirty", or just "sbr-1.0" if no commit
+// has been made since the tag. The short version keeps its VersionPrefix, so that it matches 'sbr version'.
+func (d Description) String() string {
+	short := fmt.Sprintf("%x", d.Version)
+	if len(short) > 7 {
+		short = short[:7]
+	}
+	short = VersionPrefix + short
+	s := d.Tag
+	if d.Tag == "" {
+		s = "g" + short
+	} else if d.Commits > 0 {
+		s = fmt.Sprintf("%s+%v-g%s", d.Tag, d.Commits, short)
+	}
+	if d.Dirty {
+		s += "-dirty"
+	}
+	return s
+}
+
+//Describe describes the workspace from the tags of the workspace and the subrepositories declared in '.sbr'.
+//
+// Among the tags reachable from HEAD in all of them, the most recent one wins (the most recent creation date in any
+// repository, the greatest name between equals). Commits are counted for this tag only.
+func (x *Workspace) Describe() (d Description, err error) {
+	var dirty []string
+	d.Version, dirty, err = x.DeclaredVersion(true)
+	if err != nil {
+		return d, err
+	}
+	d.Dirty = len(dirty) > 0
+
+	subs, err := x.Read()
+	if err != nil {
+		return d, err
+	}
+	prjs := []string{x.wd} // the workspace itself is part of the version too
+	for _, s := range subs {
+		prjs = append(prjs, filepath.Join(x.wd, s.rel))
+	}
+	common := make(map[string]int)    // tag -> number of repositories where it is reachable
+	created := make(map[string]int64) // tag -> most recent creation date
+	for _, prj := range prjs {
+		tags, dates, err := git.TagsMerged(prj)
+		if err != nil {
+			return d, err
+		}
+		for i, t := range tags {
+			common[t]++
+			if dates[i] > created[t] {
+				created[t] = dates[i]
+			}
+		}
+	}
+	for t, n := range common {
+		if n == len(prjs) && (d.Tag == "" || created[t] > created[d.Tag] || created[t] == created[d.Tag] && t > d.Tag) {
+			d.Tag = t
+		}
+	}
+	if d.Tag == "" {
+		return d, nil
+	}
+
+	for _, prj := range prjs {
+		_, n, err := git.RevListCount(prj, "refs/tags/"+d.Tag, "HEAD")
+		if err != nil {
+			return d, err
+		}
+		d.Commits += n
+	}
+	return d, nil
+}
@@ -0,0 +1,87 @@
+package sbr
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"testing"
+)
+
+func TestDescriptionString(t *testing.T) {
+	v := []byte{0x1a, 0x2b, 0x3c, 0x4d, 0x5e}
+	for x, d := range map[string]Description{
+		"sbr-1.0":                     {Tag: "sbr-1.0", Version: v},
+		"sbr-1.0-dirty":               {Tag: "sbr-1.0", Version: v, Dirty: true},
+		"sbr-1.0+7-gd1-1a2b3c4":       {Tag: "sbr-1.0", Commits: 7, Version: v},
+		"sbr-1.0+7-gd1-1a2b3c4-dirty": {Tag: "sbr-1.0", Commits: 7, Version: v, Dirty: true},
+		"gd1-1a2b3c4":                 {Version: v},
+	} {
+		if s := d.String(); s != x {
+			t.Errorf("invalid description %q expecting %q", s, x)
+		}
+	}
+}
+
+func TestDescribe(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not available")
+	}
+	wd, err := ioutil.TempDir("", "describe")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(wd)
+	sub := filepath.Join(wd, "a")
+
+	// git runs a git command in 'prj', commits are dated 'date' (unix time)
+	git := func(prj string, date int, args ...string) {
+		cmd := exec.Command("git", args...)
+		cmd.Dir = prj
+		d := fmt.Sprintf("@%d +0000", date)
+		cmd.Env = append(os.Environ(), "GIT_AUTHOR_NAME=t", "GIT_AUTHOR_EMAIL=t@t", "GIT_COMMITTER_NAME=t", "GIT_COMMITTER_EMAIL=t@t",
+			"GIT_AUTHOR_DATE="+d, "GIT_COMMITTER_DATE="+d)
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v %s", args, err, out)
+		}
+	}
+	describe := func(x string) {
+		d, err := NewWorkspace(wd).Describe()
+		if err != nil {
+			t.Fatalf("cannot describe: %v", err)
+		}
+		if s := d.Tag + "+" + strconv.Itoa(d.Commits); s != x {
+			t.Errorf("invalid description %s (%v) expecting %s", s, d, x)
+		}
+	}
+
+	for _, prj := range []string{wd, sub} {
+		if err := os.MkdirAll(prj, 0755); err != nil {
+			t.Fatal(err)
+		}
+		git(prj, 0, "-c", "init.defaultBranch=master", "init", "-q")
+	}
+	ioutil.WriteFile(filepath.Join(wd, SbrFile), []byte(`"a" "ra"`+"\n"), 0644)
+	ioutil.WriteFile(filepath.Join(wd, ".gitignore"), []byte("/a/\n"), 0644)
+	git(wd, 1000, "add", "-A")
+	git(wd, 1000, "commit", "-q", "-m", "top")
+	git(sub, 1000, "commit", "-q", "--allow-empty", "-m", "a")
+	git(wd, 0, "tag", "v1")
+	git(sub, 0, "tag", "v1")
+	git(sub, 0, "tag", "v9") // not in the workspace
+	describe("v1+0")
+
+	git(wd, 2000, "commit", "-q", "--allow-empty", "-m", "top change")
+	git(sub, 2000, "commit", "-q", "--allow-empty", "-m", "a change")
+	git(sub, 3000, "commit", "-q", "--allow-empty", "-m", "another a change")
+	describe("v1+3")
+
+	git(wd, 0, "tag", "v0", "v1") // as old as v1, the greatest name wins
+	git(sub, 0, "tag", "v0", "v1")
+	describe("v1+3")
+	git(wd, 0, "tag", "v2")
+	git(sub, 0, "tag", "v2")
+	describe("v2+0")
+}
@@ -0,0 +1,388 @@
+package sbr
+
+import (
+	"fmt"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode"
+
+	"github.com/ericaro/sbr/git"
+)
+
+//Repository gives access to the state of a repository on the disk.
+//
+// git is queried lazily, and only once, for each piece of information.
+type Repository struct {
+	Path string // absolute path
+	Rel  string // path relative to the workspace
+
+	branch, remote                  *string
+	dirty, ahead, behind            *int
+	branchErr, remoteErr, statusErr error
+	countErr                        error
+}
+
+//NewRepository creates a Repository for the git repository at 'path', inside 'wd'.
+func NewRepository(wd, path string) *Repository {
+	rel, err := filepath.Rel(wd, path)
+	if err != nil {
+		rel = path
+	}
+	return &Repository{Path: path, Rel: rel}
+}
+
+//Branch returns the current branch.
+func (r *Repository) Branch() (string, error) {
+	if r.branch == nil {
+		b, err := git.Branch(r.Path)
+		r.branch, r.branchErr = &b, err
+	}
+	return *r.branch, r.branchErr
+}
+
+//Remote returns the 'origin' remote url.
+func (r *Repository) Remote() (string, error) {
+	if r.remote == nil {
+		o, err := git.RemoteOrigin(r.Path)
+		r.remote, r.remoteErr = &o, err
+	}
+	return *r.remote, r.remoteErr
+}
+
+//Dirty returns the number of changed files in the working dir.
+func (r *Repository) Dirty() (int, error) {
+	if r.dirty == nil {
+		n, err := git.StatusWCL(r.Path)
+		r.dirty, r.statusErr = &n, err
+	}
+	return *r.dirty, r.statusErr
+}
+
+//AheadBehind returns the number of commits to be pushed (ahead) and pulled (behind) from the upstream.
+func (r *Repository) AheadBehind() (ahead, behind int, err error) {
+	if r.ahead == nil {
+		a, b, err := git.RevListCountHead(r.Path)
+		r.ahead, r.behind, r.countErr = &a, &b, err
+	}
+	return *r.ahead, *r.behind, r.countErr
+}
+
+//Filter selects repositories.
+type Filter func(r *Repository) bool
+
+//And returns a Filter that matches repositories matched by all 'filters'.
+// nil filters are ignored.
+func And(filters ...Filter) Filter {
+	return func(r *Repository) bool {
+		for _, f := range filters {
+			if f != nil && !f(r) {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+//PathFilter matches repositories whose relative path matches the glob pattern (see path.Match).
+func PathFilter(glob string) (Filter, error) {
+	if _, err := path.Match(glob, ""); err != nil {
+		return nil, fmt.Errorf("invalid path pattern %q: %v", glob, err)
+	}
+	return func(r *Repository) bool {
+		ok, _ := path.Match(glob, filepath.ToSlash(r.Rel))
+		return ok
+	}, nil
+}
+
+//Select returns the sorted path of repositories that match the filter 'f' (nil matches all).
+//
+// repositories are evaluated concurrently.
+func (x *Workspace) Select(f Filter) []string {
+	all := x.ScanRel()
+	sort.Strings(all)
+	if f == nil {
+		return all
+	}
+	matched := make([]bool, len(all))
+	var waiter sync.WaitGroup
+	for i, prj := range all {
+		waiter.Add(1)
+		go func(i int, prj string) {
+			defer waiter.Done()
+			matched[i] = f(NewRepository(x.wd, prj))
+		}(i, prj)
+	}
+	waiter.Wait()
+
+	res := make([]string, 0, len(all))
+	for i, prj := range all {
+		if matched[i] {
+			res = append(res, prj)
+		}
+	}
+	return res
+}
+
+//ParseFilter parses a filter expression.
+//
+// An expression combines conditions with '&&', '||', '!' and parenthesis.
+// A condition is an attribute, optionally compared to a value:
+//
+//     dirty, ahead, behind   number of changed files, commits to push, commits to pull
+//                            alone: true if not zero. Compared with ==, !=, <, <=, >, >=
+//     clean                  true if there is no changed file
+//     branch, remote, path   current branch, origin url, and path relative to the workspace
+//                            compared with == or != to a glob pattern (see path.Match)
+//
+// for instance:
+//
+//     dirty && branch!=master
+//     (ahead || behind>2) && path==src/github.com/acme/*
+//
+// A condition that cannot be evaluated (git error, no upstream) is false.
+func ParseFilter(expr string) (f Filter, err error) {
+	tokens, err := tokenize(expr)
+	if err != nil {
+		return
+	}
+	p := &filterParser{tokens: tokens}
+	f, err = p.or()
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.tokens) {
+		return nil, fmt.Errorf("filter: unexpected %q", p.tokens[p.pos])
+	}
+	return f, nil
+}
+
+//filterParser is a simple recursive descent parser over filter tokens.
+type filterParser struct {
+	tokens []string
+	pos    int
+}
+
+func (p *filterParser) peek() string {
+	if p.pos < len(p.tokens) {
+		return p.tokens[p.pos]
+	}
+	return ""
+}
+
+func (p *filterParser) next() string {
+	t := p.peek()
+	p.pos++
+	return t
+}
+
+func (p *filterParser) or() (Filter, error) {
+	left, err := p.and()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek() == "||" {
+		p.next()
+		right, err := p.and()
+		if err != nil {
+			return nil, err
+		}
+		l := left
+		left = func(r *Repository) bool { return l(r) || right(r) }
+	}
+	return left, nil
+}
+
+func (p *filterParser) and() (Filter, error) {
+	left, err := p.unary()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek() == "&&" {
+		p.next()
+		right, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		l := left
+		left = func(r *Repository) bool { return l(r) && right(r) }
+	}
+	return left, nil
+}
+
+func (p *filterParser) unary() (Filter, error) {
+	switch t := p.next(); t {
+	case "!":
+		f, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		return func(r *Repository) bool { return !f(r) }, nil
+	case "(":
+		f, err := p.or()
+		if err != nil {
+			return nil, err
+		}
+		if t := p.next(); t != ")" {
+			return nil, fmt.Errorf("filter: expecting ')' got %q", t)
+		}
+		return f, nil
+	case "":
+		return nil, fmt.Errorf("filter: unexpected end of expression")
+	default:
+		return p.condition(t)
+	}
+}
+
+//condition parses "attribute [op value]"
+func (p *filterParser) condition(attr string) (Filter, error) {
+	op := p.peek()
+	switch op {
+	case "==", "!=", "<", "<=", ">", ">=":
+		p.next()
+	default:
+		op = ""
+	}
+	var value string
+	if op != "" {
+		value = p.next()
+		if value == "" || isOperator(value) {
+			return nil, fmt.Errorf("filter: missing value after %s%s", attr, op)
+		}
+	}
+
+	switch attr {
+	case "dirty", "ahead", "behind":
+		get := numeric(attr)
+		if op == "" {
+			return func(r *Repository) bool { n, err := get(r); return err == nil && n != 0 }, nil
+		}
+		v, err := strconv.Atoi(value)
+		if err != nil {
+			return nil, fmt.Errorf("filter: %s%s expects a number, got %q", attr, op, value)
+		}
+		return func(r *Repository) bool { n, err := get(r); return err == nil && compare(n, op, v) }, nil
+
+	case "clean":
+		if op != "" {
+			return nil, fmt.Errorf("filter: 'clean' cannot be compared")
+		}
+		return func(r *Repository) bool { n, err := r.Dirty(); return err == nil && n == 0 }, nil
+
+	case "branch", "remote", "path":
+		if op != "==" && op != "!=" {
+			return nil, fmt.Errorf("filter: %s must be compared with '==' or '!=' to a pattern", attr)
+		}
+		if _, err := path.Match(value, ""); err != nil {
+			return nil, fmt.Errorf("filter: invalid pattern %q: %v", value, err)
+		}
+		get := textual(attr)
+		return func(r *Repository) bool {
+			s, err := get(r)
+			if err != nil {
+				return false
+			}
+			ok, _ := path.Match(value, s)
+			return ok == (op == "==")
+		}, nil
+	}
+	return nil, fmt.Errorf("filter: unknown attribute %q", attr)
+}
+
+//numeric returns the getter for a numeric attribute.
+func numeric(attr string) func(r *Repository) (int, error) {
+	switch attr {
+	case "ahead":
+		return func(r *Repository) (int, error) { a, _, err := r.AheadBehind(); return a, err }
+	case "behind":
+		return func(r *Repository) (int, error) { _, b, err := r.AheadBehind(); return b, err }
+	default:
+		return (*Repository).Dirty
+	}
+}
+
+//textual returns the getter for a textual attribute.
+func textual(attr string) func(r *Repository) (string, error) {
+	switch attr {
+	case "branch":
+		return (*Repository).Branch
+	case "remote":
+		return (*Repository).Remote
+	default:
+		return func(r *Repository) (string, error) { return filepath.ToSlash(r.Rel), nil }
+	}
+}
+
+func compare(n int, op string, v int) bool {
+	switch op {
+	case "==":
+		return n == v
+	case "!=":
+		return n != v
+	case "<":
+		return n < v
+	case "<=":
+		return n <= v
+	case ">":
+		return n > v
+	default:
+		return n >= v
+	}
+}
+
+var operators = []string{"&&", "||", "==", "!=", "<=", ">=", "<", ">", "!", "(", ")"}
+
+func isOperator(t string) bool {
+	for _, op := range operators {
+		if t == op {
+			return true
+		}
+	}
+	return false
+}
+
+//tokenize splits a filter expression into operators, words and quoted strings (returned unquoted).
+func tokenize(expr string) (tokens []string, err error) {
+	for i := 0; i < len(expr); {
+		c := expr[i]
+		switch {
+		case unicode.IsSpace(rune(c)):
+			i++
+			continue
+		case c == '"' || c == '\'':
+			end := strings.IndexByte(expr[i+1:], c)
+			if end < 0 {
+				return nil, fmt.Errorf("filter: unterminated string at %d", i)
+			}
+			tokens = append(tokens, expr[i+1:i+1+end])
+			i += end + 2
+			continue
+		}
+		op := ""
+		for _, o := range operators {
+			if strings.HasPrefix(expr[i:], o) {
+				op = o
+				break
+			}
+		}
+		if op != "" {
+			tokens = append(tokens, op)
+			i += len(op)
+			continue
+		}
+		if c == '&' || c == '|' || c == '=' {
+			return nil, fmt.Errorf("filter: unexpected %q at %d", c, i)
+		}
+		// a word goes up to the next space or operator char
+		j := i
+		for j < len(expr) && !unicode.IsSpace(rune(expr[j])) && !strings.ContainsRune("()!&|=<>\"'", rune(expr[j])) {
+			j++
+		}
+		tokens = append(tokens, expr[i:j])
+		i = j
+	}
+	return tokens, nil
+}
@@ -0,0 +1,67 @@
+package sbr
+
+import (
+	"path"
+	"testing"
+)
+
+//repository creates a Repository with a preloaded state (git is never called).
+func repository(rel, branch string, dirty, ahead, behind int) *Repository {
+	remote := "git@github.com:acme/" + path.Base(rel) + ".git"
+	return &Repository{
+		Path: "/ws/" + rel, Rel: rel,
+		branch: &branch, remote: &remote,
+		dirty: &dirty, ahead: &ahead, behind: &behind,
+	}
+}
+
+func TestParseFilter(t *testing.T) {
+
+	r := repository("src/github.com/acme/x", "dev", 2, 0, 3)
+
+	for expr, x := range map[string]bool{
+		"dirty":                   true,
+		"clean":                   false,
+		"ahead":                   false,
+		"behind>2":                true,
+		"behind >= 4":             false,
+		"dirty && branch!=master": true,
+		"dirty && branch==master": false,
+		"!dirty || ahead":         false,
+		"(ahead || behind) && path==src/*/acme/*":  true,
+		"path=='src/github.com/acme/x'":            true,
+		"remote==*:acme/*":                         true,
+		"!(branch==dev)":                           false,
+		"clean || dirty==2 && branch==\"d*\"":      true,
+		"ahead || behind<1 || path==src/elsewhere": false,
+	} {
+		f, err := ParseFilter(expr)
+		if err != nil {
+			t.Errorf("cannot parse %q: %v", expr, err)
+			continue
+		}
+		if m := f(r); m != x {
+			t.Errorf("%q should be %v", expr, x)
+		}
+	}
+}
+
+func TestParseFilterErrors(t *testing.T) {
+	for _, expr := range []string{
+		"",
+		"dirty &&",
+		"(dirty",
+		"dirty)",
+		"unknown",
+		"branch",
+		"branch>2",
+		"dirty==x",
+		"clean==0",
+		"dirty & ahead",
+		"path=='unterminated",
+	} {
+		if _, err := ParseFilter(expr); err == nil {
+			t.Errorf("%q should not parse", expr)
+		}
+	}
+}
@@ -0,0 +1,53 @@
+package sbr
+
+import (
+	"sort"
+	"sync"
+
+	"github.com/ericaro/sbr/git"
+)
+
+//Commit is a git commit annotated with the path of its repository.
+type Commit struct {
+	Rel string // path relative to the workspace
+	git.Commit
+}
+
+//Log runs git log with 'args' in every path (see Select), concurrently, and merges all commits in a single
+// chronological stream (most recent first, by committer date, as git log does).
+//
+// repositories where git log fails are reported in 'errs', indexed by rel.
+func (x *Workspace) Log(paths []string, args ...string) (commits []Commit, errs map[string]error) {
+	logs := make([][]git.Commit, len(paths))
+	failed := make([]error, len(paths))
+	var waiter sync.WaitGroup
+	for i, prj := range paths {
+		waiter.Add(1)
+		go func(i int, prj string) {
+			defer waiter.Done()
+			logs[i], failed[i] = git.Log(prj, args...)
+		}(i, prj)
+	}
+	waiter.Wait()
+
+	errs = make(map[string]error)
+	for i, prj := range paths {
+		rel := NewRepository(x.wd, prj).Rel
+		if failed[i] != nil {
+			errs[rel] = failed[i]
+		}
+		for _, c := range logs[i] {
+			commits = append(commits, Commit{Rel: rel, Commit: c})
+		}
+	}
+	// paths are sorted, and each log is already in git order: a stable sort keeps them
+	sort.Stable(byCommitDate(commits))
+	return commits, errs
+}
+
+//byCommitDate sorts commits, most recent first.
+type byCommitDate []Commit
+
+func (a byCommitDate) Len() int           { return len(a) }
+func (a byCommitDate) Swap(i, j int)      { a[i], a[j] = a[j], a[i] }
+func (a byCommitDate) Less(i, j int) bool { return a[i].CommitDate.After(a[j].CommitDate) }
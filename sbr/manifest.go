@@ -0,0 +1,153 @@
+package sbr
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/ericaro/sbr/git"
+)
+
+//Pin is a subrepository pinned to a revision.
+type Pin struct {
+	Sub
+	Rev string // the revision (usually a sha1)
+}
+
+//Manifest is the state of a workspace: every subrepository, and the workspace itself (rel "."), pinned to a revision.
+//
+// Unlike '.sbr' that declares branches to follow, a Manifest can be used to restore an exact state.
+//
+// A Manifest is written one pin per line, sorted by path, with fields separated by a space (quoted as in CSV when needed):
+//
+//	path remote branch revision
+type Manifest []Pin
+
+//Subs returns the subrepositories in the manifest (without the workspace itself).
+func (m Manifest) Subs() []Sub {
+	subs := make([]Sub, 0, len(m))
+	for _, p := range m {
+		if p.rel != "." {
+			subs = append(subs, p.Sub)
+		}
+	}
+	return subs
+}
+
+//Rev returns the revision pinned for 'rel' ("." for the workspace itself).
+func (m Manifest) Rev(rel string) (rev string, exists bool) {
+	for _, p := range m {
+		if p.rel == rel {
+			return p.Rev, true
+		}
+	}
+	return "", false
+}
+
+//WriteTo writes the manifest in its normalized format.
+func (m Manifest) WriteTo(w io.Writer) (n int64, err error) {
+	sorted := make(Manifest, len(m))
+	copy(sorted, m)
+	sort.Sort(byPinRel(sorted))
+
+	buf := new(bytes.Buffer)
+	cw := csv.NewWriter(buf)
+	cw.Comma = ' '
+	for _, p := range sorted {
+		cw.Write([]string{p.rel, p.remote, p.branch, p.Rev})
+	}
+	cw.Flush()
+	if err = cw.Error(); err != nil {
+		return 0, err
+	}
+	return buf.WriteTo(w)
+}
+
+//ReadManifest reads a manifest from 'r'.
+func ReadManifest(r io.Reader) (m Manifest, err error) {
+	w := csv.NewReader(r)
+	w.Comma = ' '
+	w.FieldsPerRecord = 4
+	w.Comment = '#'
+
+	records, err := w.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	m = make(Manifest, 0, len(records))
+	for _, record := range records {
+		m = append(m, Pin{New(record[0], record[1], record[2]), record[3]})
+	}
+	return m, nil
+}
+
+//ReadManifestFile reads a manifest from a file.
+func ReadManifestFile(filename string) (m Manifest, err error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ReadManifest(f)
+}
+
+//Manifest pins every declared subrepository, and the workspace itself, to their current HEAD.
+func (x *Workspace) Manifest() (m Manifest, err error) {
+	subs, err := x.Read()
+	if err != nil {
+		return nil, err
+	}
+	branch, _ := git.Branch(x.wd)
+	remote, _ := git.RemoteOrigin(x.wd)
+	subs = append(subs, New(".", remote, branch))
+
+	m = make(Manifest, 0, len(subs))
+	for _, s := range subs {
+		sha, err := git.RevParseHead(filepath.Join(x.wd, s.rel))
+		if err != nil {
+			return nil, fmt.Errorf("cannot pin %s: %v", s.rel, err)
+		}
+		m = append(m, Pin{s, sha})
+	}
+	sort.Sort(byPinRel(m))
+	return m, nil
+}
+
+//TagManifest pins the subrepositories declared in '.sbr' at the workspace revision 'rev', and the workspace itself, to 'rev'.
+//
+// It is the state of a workspace when the same tag (or branch) is applied to all repositories.
+//
+// Subrepositories without a declared branch follow 'rev' if it is a branch, or the current workspace branch (as in Read).
+func (x *Workspace) TagManifest(rev string) (m Manifest, err error) {
+	content, err := git.Show(x.wd, rev, x.filename)
+	if err != nil {
+		return nil, err
+	}
+	branch := rev
+	if exists, _ := git.BranchExists(x.wd, rev); !exists {
+		if branch, err = git.Branch(x.wd); err != nil {
+			branch = "master" // default
+		}
+	}
+	subs, err := ReadFromBranch(branch, bytes.NewReader(content))
+	if err != nil {
+		return nil, err
+	}
+	remote, _ := git.RemoteOrigin(x.wd)
+	m = Manifest{Pin{New(".", remote, ""), rev}}
+	for _, s := range subs {
+		m = append(m, Pin{s, rev})
+	}
+	sort.Sort(byPinRel(m))
+	return m, nil
+}
+
+type byPinRel []Pin
+
+func (a byPinRel) Len() int           { return len(a) }
+func (a byPinRel) Swap(i, j int)      { a[i], a[j] = a[j], a[i] }
+func (a byPinRel) Less(i, j int) bool { return a[i].rel < a[j].rel }
@@ -0,0 +1,55 @@
+package sbr
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+func TestManifest(t *testing.T) {
+
+	m := Manifest{
+		{New("src/b", "rb", "dev"), "b1"},
+		{New(".", "rtop", "master"), "t1"},
+		{New("src/a", "ra", "master"), "a1"},
+	}
+	buf := new(bytes.Buffer)
+	m.WriteTo(buf)
+
+	x := `. rtop master t1
+src/a ra master a1
+src/b rb dev b1
+`
+	if buf.String() != x {
+		t.Errorf("invalid manifest format:\n%s\nexpecting\n%s", buf.String(), x)
+	}
+
+	r, err := ReadManifest(buf)
+	if err != nil {
+		t.Fatalf("cannot read manifest: %v", err)
+	}
+	if !reflect.DeepEqual(r, Manifest{m[1], m[2], m[0]}) {
+		t.Errorf("manifest should be equals: %v vs %v", r, m)
+	}
+	if subs := r.Subs(); !Equals(subs, []Sub{m[2].Sub, m[0].Sub}) {
+		t.Errorf("invalid subs %v", subs)
+	}
+	if rev, _ := r.Rev("."); rev != "t1" {
+		t.Errorf("invalid top revision %q", rev)
+	}
+}
+
+func TestManifestEscaping(t *testing.T) {
+	m := Manifest{{New("src/a b", `C:\remotes\a`, `quote"d`), "a1"}}
+	buf := new(bytes.Buffer)
+	if _, err := m.WriteTo(buf); err != nil {
+		t.Fatalf("cannot write manifest: %v", err)
+	}
+	r, err := ReadManifest(buf)
+	if err != nil {
+		t.Fatalf("cannot read manifest: %v", err)
+	}
+	if !reflect.DeepEqual(r, m) {
+		t.Errorf("manifest should be equals: %v vs %v", r, m)
+	}
+}
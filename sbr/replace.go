@@ -0,0 +1,91 @@
+package sbr
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/ericaro/sbr/git"
+)
+
+//Edit is a change of a file content.
+type Edit struct {
+	Path     string // absolute path
+	Name     string // path relative to the repository
+	Old, New string
+}
+
+//Diff returns the change in the unified diff format.
+func (e Edit) Diff() string { return UnifiedDiff(e.Name, e.Old, e.New) }
+
+//Apply writes the new content (if the file has not changed in the meantime).
+func (e Edit) Apply() error {
+	info, err := os.Lstat(e.Path)
+	if err != nil {
+		return err
+	}
+	if !info.Mode().IsRegular() {
+		return &os.PathError{Op: "replace", Path: e.Path, Err: errChanged}
+	}
+	current, err := ioutil.ReadFile(e.Path)
+	if err != nil {
+		return err
+	}
+	if string(current) != e.Old {
+		return &os.PathError{Op: "replace", Path: e.Path, Err: errChanged}
+	}
+	return ioutil.WriteFile(e.Path, []byte(e.New), info.Mode())
+}
+
+var errChanged = errors.New("file has changed since the preview")
+
+//Replace computes the edits that replace all matches of 're' by 'repl' (see regexp.Regexp.ReplaceAllString) in
+// the files tracked by the repository 'prj'.
+//
+// Only files matching 'glob' are edited: the glob matches the file name, or its path in the repository if the glob
+// contains a '/'. An empty glob matches all files. Binary files, symlinks and submodules are skipped.
+func Replace(prj string, re *regexp.Regexp, repl, glob string) (edits []Edit, err error) {
+	files, err := git.LsFiles(prj)
+	if err != nil {
+		return nil, err
+	}
+	for _, name := range files {
+		if glob != "" {
+			target := path.Base(name)
+			if strings.Contains(glob, "/") {
+				target = name
+			}
+			if ok, _ := path.Match(glob, target); !ok {
+				continue
+			}
+		}
+		p := filepath.Join(prj, filepath.FromSlash(name))
+		info, err := os.Lstat(p)
+		if os.IsNotExist(err) { // deleted, but not committed
+			continue
+		}
+		if err != nil {
+			return edits, err
+		}
+		if !info.Mode().IsRegular() { // symlinks (their target may be out of the repository), submodules
+			continue
+		}
+		content, err := ioutil.ReadFile(p)
+		if err != nil {
+			return edits, err
+		}
+		if bytes.IndexByte(content, 0) >= 0 { // binary
+			continue
+		}
+		old := string(content)
+		if n := re.ReplaceAllString(old, repl); n != old {
+			edits = append(edits, Edit{Path: p, Name: name, Old: old, New: n})
+		}
+	}
+	return edits, nil
+}
@@ -0,0 +1,209 @@
+package sbr
+
+import (
+	"encoding/csv"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/ericaro/sbr/git"
+)
+
+//Stash is a workspace stash: the git stash entries created together in several repositories.
+//
+// Workspace stashes are kept in the workspace state (see Workspace.StateDir), the most recent first. Each one is
+// written as a record "date message", followed by a record per repository, as in a Manifest (the revision being the
+// git stash entry).
+type Stash struct {
+	Date    time.Time
+	Message string
+	Repos   Manifest
+}
+
+//Stashes reads all workspace stashes, the most recent first.
+func (x *Workspace) Stashes() (stashes []Stash, err error) {
+	f, err := os.Open(x.StateDir("stash"))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	r := csv.NewReader(f)
+	r.Comma = ' '
+	r.FieldsPerRecord = -1
+	records, err := r.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	for i, record := range records {
+		switch len(record) {
+		case 2:
+			date, err := time.Parse(time.RFC3339, record[0])
+			if err != nil {
+				return nil, fmt.Errorf("invalid stash date %q: %v", record[0], err)
+			}
+			stashes = append(stashes, Stash{Date: date, Message: record[1]})
+		case 4:
+			if len(stashes) == 0 {
+				return nil, fmt.Errorf("invalid %vth stash record: no stash declared", i)
+			}
+			s := &stashes[len(stashes)-1]
+			s.Repos = append(s.Repos, Pin{New(record[0], record[1], record[2]), record[3]})
+		default:
+			return nil, fmt.Errorf("invalid %vth stash record #fields must be 2 or 4 not %v", i, len(record))
+		}
+	}
+	return stashes, nil
+}
+
+//writeStashes rewrites all workspace stashes.
+func (x *Workspace) writeStashes(stashes []Stash) (err error) {
+	filename := x.StateDir("stash")
+	if len(stashes) == 0 {
+		err = os.Remove(filename)
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	if err = os.MkdirAll(filepath.Dir(filename), 0755); err != nil {
+		return err
+	}
+	f, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	for _, s := range stashes {
+		w := csv.NewWriter(f)
+		w.Comma = ' '
+		w.Write([]string{s.Date.Format(time.RFC3339), s.Message})
+		w.Flush()
+		if err = w.Error(); err != nil {
+			f.Close()
+			return err
+		}
+		if _, err = s.Repos.WriteTo(f); err != nil {
+			f.Close()
+			return err
+		}
+	}
+	return f.Close()
+}
+
+//StashPush stashes local changes (including untracked files) in all dirty repositories, the workspace included,
+// and records them as a single workspace stash.
+//
+// repositories that could not be stashed are reported in 'errs', indexed by rel.
+func (x *Workspace) StashPush(message string) (stash Stash, errs map[string]error, err error) {
+	stashes, err := x.Stashes() // before stashing anything
+	if err != nil {
+		return stash, nil, err
+	}
+	stash = Stash{Date: time.Now(), Message: message}
+	paths := x.ScanRel()
+	pins := make([]*Pin, len(paths))
+	failed := make([]error, len(paths))
+	var waiter sync.WaitGroup
+	for i, prj := range paths {
+		waiter.Add(1)
+		go func(i int, prj string) {
+			defer waiter.Done()
+			if changed, err := HasLocalChanges(prj); err != nil || !changed { // nested repositories are not changes
+				failed[i] = err
+				return
+			}
+			sha, err := git.StashPush(prj, "sbr stash: "+message)
+			if err != nil || sha == "" {
+				failed[i] = err
+				return
+			}
+			r := NewRepository(x.wd, prj)
+			branch, _ := r.Branch()
+			remote, _ := r.Remote()
+			pins[i] = &Pin{New(r.Rel, remote, branch), sha}
+		}(i, prj)
+	}
+	waiter.Wait()
+
+	errs = make(map[string]error)
+	for i, prj := range paths {
+		if failed[i] != nil {
+			errs[NewRepository(x.wd, prj).Rel] = failed[i]
+		}
+		if pins[i] != nil {
+			stash.Repos = append(stash.Repos, *pins[i])
+		}
+	}
+	if len(stash.Repos) == 0 {
+		return stash, errs, nil
+	}
+	sort.Sort(byPinRel(stash.Repos))
+
+	if err = x.writeStashes(append([]Stash{stash}, stashes...)); err != nil {
+		// the workspace stash is lost: give the changes back
+		for _, p := range stash.Repos {
+			if res, e := git.StashPop(filepath.Join(x.wd, p.rel), p.Rev); e != nil {
+				errs[p.rel] = fmt.Errorf("%v %s", e, res)
+			}
+		}
+		return stash, errs, err
+	}
+	return stash, errs, nil
+}
+
+//StashPop applies the workspace stash 'i' (0 is the most recent) to all its repositories, and removes it.
+//
+// repositories where the stash could not be applied (e.g. conflicts) are reported in 'errs', indexed by rel, and
+// are kept in the workspace stash.
+func (x *Workspace) StashPop(i int) (stash Stash, errs map[string]error, err error) {
+	return x.stash(i, git.StashPop)
+}
+
+//StashDrop removes the workspace stash 'i' (0 is the most recent), and the git stash entries it is made of.
+func (x *Workspace) StashDrop(i int) (stash Stash, errs map[string]error, err error) {
+	return x.stash(i, git.StashDrop)
+}
+
+//stash applies 'f' to all repositories of the workspace stash 'i', and removes those that succeeded.
+func (x *Workspace) stash(i int, f func(prj, sha string) (string, error)) (stash Stash, errs map[string]error, err error) {
+	stashes, err := x.Stashes()
+	if err != nil {
+		return stash, nil, err
+	}
+	if i < 0 || i >= len(stashes) {
+		return stash, nil, fmt.Errorf("no workspace stash@{%d}", i)
+	}
+	stash = stashes[i]
+
+	failed := make([]error, len(stash.Repos))
+	var waiter sync.WaitGroup
+	for j, p := range stash.Repos {
+		waiter.Add(1)
+		go func(j int, p Pin) {
+			defer waiter.Done()
+			_, failed[j] = f(filepath.Join(x.wd, p.Rel()), p.Rev)
+		}(j, p)
+	}
+	waiter.Wait()
+
+	errs = make(map[string]error)
+	remaining := make(Manifest, 0, len(stash.Repos))
+	for j, p := range stash.Repos {
+		if failed[j] != nil {
+			errs[p.Rel()] = failed[j]
+			remaining = append(remaining, p)
+		}
+	}
+	if len(remaining) > 0 {
+		stashes[i].Repos = remaining
+	} else {
+		stashes = append(stashes[:i], stashes[i+1:]...)
+	}
+	return stash, errs, x.writeStashes(stashes)
+}
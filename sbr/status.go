@@ -0,0 +1,138 @@
+package sbr
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/ericaro/sbr/git"
+)
+
+//Status is the state of a repository, compared to its upstream (or any ref), and to its declaration in '.sbr'.
+type Status struct {
+	Rel            string `json:"rel"`
+	Declared       bool   `json:"declared"` // false for the workspace itself, and undeclared subrepositories
+	Branch         string `json:"branch"`
+	DeclaredBranch string `json:"declared_branch"`
+	Remote         string `json:"remote"`
+	DeclaredRemote string `json:"declared_remote"`
+	Ahead          int    `json:"ahead"`  // commits to be pushed
+	Behind         int    `json:"behind"` // commits to be pulled
+	Dirty          int    `json:"dirty"`  // changed files in the working dir
+	Stash          int    `json:"stash"`  // stash entries
+	Error          string `json:"error,omitempty"`
+
+	CountErr error `json:"-"` // Ahead and Behind are unknown (see git.ErrNoUpstream)
+	DirtyErr error `json:"-"` // Dirty is unknown
+	StashErr error `json:"-"` // Stash is unknown
+}
+
+//MarshalJSON writes unknown numbers (see CountErr, DirtyErr and StashErr) as null, not to be confused with 0.
+func (s Status) MarshalJSON() ([]byte, error) {
+	type status Status // without this method
+	x := struct {
+		status
+		Ahead  *int `json:"ahead"`
+		Behind *int `json:"behind"`
+		Dirty  *int `json:"dirty"`
+		Stash  *int `json:"stash"`
+	}{status: status(s)}
+	if s.CountErr == nil {
+		x.Ahead, x.Behind = &s.Ahead, &s.Behind
+	}
+	if s.DirtyErr == nil {
+		x.Dirty = &s.Dirty
+	}
+	if s.StashErr == nil {
+		x.Stash = &s.Stash
+	}
+	return json.Marshal(x)
+}
+
+//NewStatus computes the Status of 'r', given the declared subrepositories indexed by rel.
+//
+// commits are counted against the ref returned by 'ref', or against the upstream if 'ref' is nil.
+func NewStatus(r *Repository, declared map[string]Sub, ref func(r *Repository) (string, error)) Status {
+	s := Status{Rel: r.Rel}
+	s.Branch, _ = r.Branch()
+	s.Remote, _ = r.Remote()
+	if d, exists := declared[r.Rel]; exists {
+		s.Declared, s.DeclaredBranch, s.DeclaredRemote = true, d.Branch(), d.Remote()
+	}
+
+	if ref == nil {
+		s.Ahead, s.Behind, s.CountErr = r.AheadBehind()
+	} else if rf, err := ref(r); err != nil {
+		s.CountErr = err
+	} else if !git.RefExists(r.Path, rf) {
+		s.CountErr = fmt.Errorf("unknown ref %q", rf)
+	} else {
+		s.Ahead, s.Behind, s.CountErr = git.RevListCount(r.Path, "HEAD", rf)
+	}
+	s.Dirty, s.DirtyErr = r.Dirty()
+	s.Stash, s.StashErr = git.StashCount(r.Path)
+
+	errs := make([]string, 0, 3)
+	for _, err := range []error{s.DirtyErr, s.CountErr, s.StashErr} {
+		if err != nil {
+			errs = append(errs, strings.Replace(err.Error(), "\n", "; ", -1))
+		}
+	}
+	s.Error = strings.Join(errs, "; ")
+	return s
+}
+
+//Drifts describes how the repository differs from its declaration in '.sbr'.
+//
+// the workspace itself (".") never drifts.
+func (s Status) Drifts() []string {
+	if s.Rel == "." {
+		return nil
+	}
+	if !s.Declared {
+		return []string{"not in .sbr"}
+	}
+	drifts := make([]string, 0, 2)
+	if s.Branch != "" && s.Branch != s.DeclaredBranch {
+		drifts = append(drifts, fmt.Sprintf("on %q instead of %q", s.Branch, s.DeclaredBranch))
+	}
+	if s.Remote != "" && s.Remote != s.DeclaredRemote {
+		drifts = append(drifts, fmt.Sprintf("remote %q instead of %q", s.Remote, s.DeclaredRemote))
+	}
+	return drifts
+}
+
+//Clean is true when there is nothing to push, pull, or commit, and no drift.
+//
+// a repository whose counts or changes are unknown is never clean.
+func (s Status) Clean() bool {
+	if s.CountErr != nil || s.DirtyErr != nil {
+		return false
+	}
+	return s.Ahead == 0 && s.Behind == 0 && s.Dirty == 0 && len(s.Drifts()) == 0
+}
+
+//Status computes the Status of every path (see Select), concurrently.
+//
+// 'ref' returns the ref to compare each repository to, nil means the upstream.
+// Declarations are read from '.sbr', if it cannot be read, every subrepository is undeclared.
+func (x *Workspace) Status(paths []string, ref func(r *Repository) (string, error)) []Status {
+	declared := make(map[string]Sub)
+	subs, _ := x.Read()
+	for _, s := range subs {
+		declared[s.Rel()] = s
+	}
+
+	status := make([]Status, len(paths))
+	var waiter sync.WaitGroup
+	for i, prj := range paths {
+		waiter.Add(1)
+		go func(i int, prj string) {
+			defer waiter.Done()
+			status[i] = NewStatus(NewRepository(x.wd, prj), declared, ref)
+		}(i, prj)
+	}
+	waiter.Wait()
+	return status
+}
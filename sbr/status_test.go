@@ -0,0 +1,51 @@
+package sbr
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestStatusDrifts(t *testing.T) {
+
+	for _, c := range []struct {
+		s Status
+		x []string
+	}{
+		{Status{Rel: "."}, nil},
+		{Status{Rel: "a"}, []string{"not in .sbr"}},
+		{Status{Rel: "a", Declared: true, Branch: "dev", DeclaredBranch: "dev", Remote: "r", DeclaredRemote: "r"}, []string{}},
+		{Status{Rel: "a", Declared: true, Branch: "dev", DeclaredBranch: "master", Remote: "r", DeclaredRemote: "r"}, []string{`on "dev" instead of "master"`}},
+		{Status{Rel: "a", Declared: true, Branch: "dev", DeclaredBranch: "dev", Remote: "o", DeclaredRemote: "r"}, []string{`remote "o" instead of "r"`}},
+	} {
+		if d := c.s.Drifts(); !reflect.DeepEqual(d, c.x) {
+			t.Errorf("%v drifts should be %q, got %q", c.s, c.x, d)
+		}
+	}
+}
+
+func TestStatusClean(t *testing.T) {
+	for s, x := range map[*Status]bool{
+		&Status{Rel: "."}:                                   true,
+		&Status{Rel: ".", Ahead: 1}:                         false,
+		&Status{Rel: ".", CountErr: errors.New("no ref")}:   false,
+		&Status{Rel: ".", DirtyErr: errors.New("no index")}: false,
+	} {
+		if c := s.Clean(); c != x {
+			t.Errorf("%v should be clean=%v", *s, x)
+		}
+	}
+}
+
+func TestStatusJSON(t *testing.T) {
+	s := Status{Rel: "a", Dirty: 2, CountErr: errors.New("no upstream"), Error: "no upstream"}
+	b, err := json.Marshal(s)
+	if err != nil {
+		t.Fatalf("cannot marshal status: %v", err)
+	}
+	x := `{"rel":"a","declared":false,"branch":"","declared_branch":"","remote":"","declared_remote":"","error":"no upstream","ahead":null,"behind":null,"dirty":2,"stash":0}`
+	if string(b) != x {
+		t.Errorf("invalid json:\n%s\nexpecting\n%s", b, x)
+	}
+}
@@ -0,0 +1,141 @@
+package sbr
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/ericaro/sbr/git"
+)
+
+//Switch switches the workspace to another branch: the top repository is checked out to 'branch', then subrepositories
+// are changed to match its '.sbr': new ones are cloned, others are switched to their declared branch and remote, and
+// old ones are pruned (if prune is set).
+//
+// The top repository must be on a branch, and all repositories must be clean. If any subrepository cannot be changed, the workspace is switched back: new clones are
+// removed, subrepositories and the top repository are switched back to their previous branch. Pruning happens last,
+// once everything else succeeded.
+func (ch *Checkouter) Switch(branch string) (err error) {
+	wd := ch.wk.Wd()
+
+	var dirty []string
+	for _, prj := range ch.wk.ScanRel() {
+		// untracked subrepositories (nested in the top one) are not changes
+		if changed, err := HasLocalChanges(prj); err != nil || changed {
+			dirty = append(dirty, NewRepository(wd, prj).Rel)
+		}
+	}
+	if len(dirty) > 0 {
+		return fmt.Errorf("uncommitted changes in %s, commit or stash them", strings.Join(dirty, ", "))
+	}
+
+	previous, err := git.Branch(wd)
+	if err != nil {
+		return err
+	}
+	if previous == "HEAD" {
+		return fmt.Errorf("the workspace is in detached HEAD mode, it could not be switched back")
+	}
+	if err = git.Checkout(wd, branch, false); err != nil {
+		fmt.Fprintf(ch.w, "ERR  Switching '/'   : %s\n", err.Error())
+		return err
+	}
+	fmt.Fprintf(ch.w, "     Switching '/' from %s to %s\n", previous, branch)
+
+	wds, err := ch.wk.Scan()
+	if err == nil {
+		var sbrs []Sub
+		if sbrs, err = ch.wk.Read(); err == nil {
+			ins, del, upd := Diff(wds, sbrs)
+			if err = ch.switchAll(ins, upd); err == nil {
+				ch.pruneAll(del)
+				return nil
+			}
+		}
+	}
+
+	// switch the top back (subrepositories have already been switched back)
+	if e := git.Checkout(wd, previous, false); e != nil {
+		fmt.Fprintf(ch.w, "ERR  Switching back '/'   : %s\n", e.Error())
+		return fmt.Errorf("%v, and the workspace could not be switched back to %s: %v", err, previous, e)
+	}
+	fmt.Fprintf(ch.w, "     Switching back '/' to %s\n", previous)
+	return fmt.Errorf("%v, workspace switched back to %s", err, previous)
+}
+
+//switchAll clones 'ins' and applies 'upd'. If anything fails, all changes are reverted.
+func (ch *Checkouter) switchAll(ins []Sub, upd []Delta) (err error) {
+	var errs []error
+	var mu sync.Mutex
+	var waiter sync.WaitGroup
+	cloned := make([]Sub, 0, len(ins))
+	for _, s := range ins {
+		waiter.Add(1)
+		go func(d Sub) {
+			defer waiter.Done()
+			res, err := git.Clone(ch.wk.Wd(), d.Rel(), d.Remote(), d.Branch())
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				fmt.Fprintf(ch.w, "ERR  Cloning into '%s'   : %q\n%s\n", d.Rel(), err.Error(), res)
+				errs = append(errs, err)
+				return
+			}
+			fmt.Fprintf(ch.w, "     Cloning into '%s'...\n", d.Rel())
+			cloned = append(cloned, d)
+		}(s)
+	}
+	waiter.Wait()
+
+	changed := make([]Delta, 0, len(upd)) // attempted changes, even partially applied
+	if len(errs) == 0 {
+		for _, delta := range upd {
+			changed = append(changed, delta)
+			if delta.Old.branch != delta.New.branch {
+				git.Fetch(ch.locate(delta.Rel())) // best effort, so that a new branch starts from the remote one
+			}
+			if _, err := ch.UpdateRepository(delta); err != nil {
+				fmt.Fprintf(ch.w, "ERR  Changing '%s'   : %s\n%s\n", delta.Rel(), err.Error(), delta.String())
+				errs = append(errs, err)
+				break
+			}
+			fmt.Fprintf(ch.w, "     Changing %s\n", delta.String())
+		}
+	}
+	if len(errs) == 0 {
+		return nil
+	}
+
+	// roll back
+	for _, d := range cloned {
+		if err := ch.Prune(d); err != nil {
+			fmt.Fprintf(ch.w, "ERR  Removing '%s'   : %q\n", d.Rel(), err.Error())
+		} else {
+			fmt.Fprintf(ch.w, "     Removing '%s'...\n", d.Rel())
+		}
+	}
+	for _, delta := range changed {
+		back := Delta{Old: delta.New, New: delta.Old}
+		if _, err := ch.UpdateRepository(back); err != nil {
+			fmt.Fprintf(ch.w, "ERR  Changing back '%s'   : %s\n", delta.Rel(), err.Error())
+		} else {
+			fmt.Fprintf(ch.w, "     Changing back %s\n", back.String())
+		}
+	}
+	return fmt.Errorf("Errors occured (%v) during operations", len(errs))
+}
+
+//pruneAll prunes 'del' if prune is set, or just reports them.
+func (ch *Checkouter) pruneAll(del []Sub) {
+	for _, d := range del {
+		if !ch.prune {
+			fmt.Fprintf(ch.w, "     Would Prune %s %s %s\n", d.Rel(), d.Remote(), d.Branch())
+			continue
+		}
+		if err := ch.Prune(d); err != nil {
+			fmt.Fprintf(ch.w, "ERR  Pruning '%s'   : %q\n", d.Rel(), err.Error())
+		} else {
+			fmt.Fprintf(ch.w, "     Pruning '%s'...\n", d.Rel())
+		}
+	}
+}
@@ -0,0 +1,193 @@
+package sbr
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+//context is the number of unchanged lines around changes in unified diffs.
+const context = 3
+
+//edit is a single line operation: ' ' (keep), '-' (delete) or '+' (insert).
+type edit struct {
+	op   byte
+	line string
+}
+
+//UnifiedDiff returns the differences between the content 'a' and 'b' of the file 'name', in the unified format.
+//
+// it returns an empty string if there are no differences.
+func UnifiedDiff(name, a, b string) string {
+	edits := diffLines(splitLines(a), splitLines(b))
+
+	// line numbers (0 based) before each edit
+	olds, news := make([]int, len(edits)+1), make([]int, len(edits)+1)
+	for i, e := range edits {
+		olds[i+1], news[i+1] = olds[i], news[i]
+		if e.op != '+' {
+			olds[i+1]++
+		}
+		if e.op != '-' {
+			news[i+1]++
+		}
+	}
+
+	buf := new(bytes.Buffer)
+	for i := 0; i < len(edits); {
+		// look for the next change
+		for i < len(edits) && edits[i].op == ' ' {
+			i++
+		}
+		if i == len(edits) {
+			break
+		}
+		if buf.Len() == 0 {
+			fmt.Fprintf(buf, "--- a/%s\n+++ b/%s\n", name, name)
+		}
+		// the hunk extends as long as changes are close enough
+		start, end := max(0, i-context), i
+		for {
+			for end < len(edits) && edits[end].op != ' ' {
+				end++
+			}
+			next := end
+			for next < len(edits) && edits[next].op == ' ' {
+				next++
+			}
+			if next == len(edits) || next-end > 2*context {
+				break
+			}
+			end = next
+		}
+		stop := end + context
+		if stop > len(edits) {
+			stop = len(edits)
+		}
+
+		fmt.Fprintf(buf, "@@ -%s +%s @@\n", hunkRange(olds[start], olds[stop]-olds[start]), hunkRange(news[start], news[stop]-news[start]))
+		for _, e := range edits[start:stop] {
+			buf.WriteByte(e.op)
+			buf.WriteString(e.line)
+			if !strings.HasSuffix(e.line, "\n") {
+				buf.WriteString("\n\\ No newline at end of file\n")
+			}
+		}
+		i = stop
+	}
+	return buf.String()
+}
+
+//hunkRange formats a hunk range, 'start' is 0 based.
+func hunkRange(start, count int) string {
+	if count == 0 { // empty ranges refer to the line before
+		return fmt.Sprintf("%d,0", start)
+	}
+	if count == 1 {
+		return fmt.Sprintf("%d", start+1)
+	}
+	return fmt.Sprintf("%d,%d", start+1, count)
+}
+
+//splitLines splits 's' in lines, keeping the line terminator.
+func splitLines(s string) []string {
+	lines := strings.SplitAfter(s, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+//diffLines computes the shortest edit script from 'a' to 'b' (Myers' algorithm, in linear space).
+func diffLines(a, b []string) []edit {
+	return appendDiff(make([]edit, 0, len(a)+len(b)), a, b)
+}
+
+//appendDiff appends the edits from 'a' to 'b' to 'edits'.
+//
+// common prefix and suffix are kept, and the remaining is split around the middle snake of its shortest edit script.
+func appendDiff(edits []edit, a, b []string) []edit {
+	for len(a) > 0 && len(b) > 0 && a[0] == b[0] {
+		edits = append(edits, edit{' ', a[0]})
+		a, b = a[1:], b[1:]
+	}
+	n, m := len(a), len(b)
+	for n > 0 && m > 0 && a[n-1] == b[m-1] {
+		n, m = n-1, m-1
+	}
+	suffix := a[n:]
+	a, b = a[:n], b[:m]
+
+	switch {
+	case n == 0:
+		for _, line := range b {
+			edits = append(edits, edit{'+', line})
+		}
+	case m == 0:
+		for _, line := range a {
+			edits = append(edits, edit{'-', line})
+		}
+	default: // at least 2 differences, so both halves are smaller
+		x, y, u, v := middleSnake(a, b)
+		edits = appendDiff(edits, a[:x], b[:y])
+		for _, line := range a[x:u] {
+			edits = append(edits, edit{' ', line})
+		}
+		edits = appendDiff(edits, a[u:], b[v:])
+	}
+
+	for _, line := range suffix {
+		edits = append(edits, edit{' ', line})
+	}
+	return edits
+}
+
+//middleSnake finds the middle snake, from (x, y) to (u, v), of a shortest edit script from 'a' to 'b', searching
+// forward from the start, and backward from the end, at the same time.
+func middleSnake(a, b []string) (x, y, u, v int) {
+	n, m := len(a), len(b)
+	delta := n - m
+	odd := delta%2 != 0
+	offset := (n+m+1)/2 + 1
+	forward := make([]int, 2*offset+1)  // furthest x on each diagonal k = x - y
+	backward := make([]int, 2*offset+1) // furthest distance to the end of 'a' on each diagonal from the end
+
+	for d := 0; d < offset; d++ {
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && forward[offset+k-1] < forward[offset+k+1]) {
+				x = forward[offset+k+1] // down: insertion
+			} else {
+				x = forward[offset+k-1] + 1 // right: deletion
+			}
+			y := x - k
+			sx, sy := x, y
+			for x < n && y < m && a[x] == b[y] {
+				x, y = x+1, y+1
+			}
+			forward[offset+k] = x
+			// diagonal k is diagonal delta-k from the end
+			if r := delta - k; odd && r >= -(d-1) && r <= d-1 && x+backward[offset+r] >= n {
+				return sx, sy, x, y
+			}
+		}
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && backward[offset+k-1] < backward[offset+k+1]) {
+				x = backward[offset+k+1]
+			} else {
+				x = backward[offset+k-1] + 1
+			}
+			y := x - k
+			sx, sy := x, y
+			for x < n && y < m && a[n-1-x] == b[m-1-y] {
+				x, y = x+1, y+1
+			}
+			backward[offset+k] = x
+			if f := delta - k; !odd && f >= -d && f <= d && x+forward[offset+f] >= n {
+				return n - x, m - y, n - sx, m - sy
+			}
+		}
+	}
+	panic("no middle snake") // cannot happen: there is always a path
+}
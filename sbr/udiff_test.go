@@ -0,0 +1,60 @@
+package sbr
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestUnifiedDiff(t *testing.T) {
+
+	a := "1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n"
+	b := "1\n2\n3\n4\nfive\n6\n7\n8\n9\n10\n11\n"
+
+	x := `--- a/f
++++ b/f
+@@ -2,9 +2,10 @@
+ 2
+ 3
+ 4
+-5
++five
+ 6
+ 7
+ 8
+ 9
+ 10
++11
+`
+	if d := UnifiedDiff("f", a, b); d != x {
+		t.Errorf("invalid diff:\n%s\nexpecting:\n%s", d, x)
+	}
+	if d := UnifiedDiff("f", a, a); d != "" {
+		t.Errorf("diff of equal contents should be empty, got:\n%s", d)
+	}
+}
+
+func TestDiffLines(t *testing.T) {
+	a := splitLines("a\nb\nc\na\nb\nb\na\n")
+	b := splitLines("c\nb\na\nb\na\nc\n")
+
+	edits := diffLines(a, b)
+	var olds, news []string
+	changes := 0
+	for _, e := range edits {
+		if e.op != '+' {
+			olds = append(olds, e.line)
+		}
+		if e.op != '-' {
+			news = append(news, e.line)
+		}
+		if e.op != ' ' {
+			changes++
+		}
+	}
+	if strings.Join(olds, "") != strings.Join(a, "") || strings.Join(news, "") != strings.Join(b, "") {
+		t.Errorf("edits do not transform a into b: %v", edits)
+	}
+	if changes != 5 { // the example of Myers' paper
+		t.Errorf("invalid number of changes %v expecting 5: %v", changes, edits)
+	}
+}
@@ -4,6 +4,8 @@ import (
 	"crypto/sha1"
 	"errors"
 	"fmt"
+	"io"
+	"io/ioutil"
 	"os"
 	"path/filepath"
 	"sort"
@@ -14,6 +16,9 @@ import (
 
 const (
 	SbrFile = ".sbr"
+	//VersionPrefix starts the text form of versions computed by DeclaredVersion ('d' for declared, and the scheme
+	// revision), so that they are not confused with versions computed from a filesystem scan (Version).
+	VersionPrefix = "d1-"
 )
 
 var (
@@ -58,6 +63,21 @@ func (x *Workspace) Sbrfile() string { return filepath.Join(x.wd, x.filename) }
 //Wd return the current working directory for this workspace.
 func (x *Workspace) Wd() string { return x.wd }
 
+//Write rewrites the '.sbr' file with 'subs', in the normalized format.
+func (x *Workspace) Write(subs []Sub) error {
+	f, err := os.Create(x.Sbrfile())
+	if err != nil {
+		return err
+	}
+	WriteTo(f, subs)
+	return f.Close()
+}
+
+//StateDir returns the directory where sbr keeps the local state of the workspace (inside the top repository's '.git').
+func (x *Workspace) StateDir(elem ...string) string {
+	return filepath.Join(append([]string{x.wd, ".git", "sbr"}, elem...)...)
+}
+
 //Read returns the []Sub, as declared in the .sbr file
 func (x *Workspace) Read() (sbrs []Sub, err error) {
 
@@ -152,6 +172,163 @@ func (wk *Workspace) Version() (version []byte, err error) {
 	return v, nil
 }
 
+//WorktreeVersion computes the workspace version like Version, but also hashes local changes in each repository: changes
+// to tracked files, and untracked files (see hashChanges). Without local changes, it is equal to Version.
+//
+// It also returns the path (relative to the workspace) of repositories with local changes.
+func (wk *Workspace) WorktreeVersion() (version []byte, dirty []string, err error) {
+	all := wk.ScanRel()
+	sort.Strings(all)
+	errs := make([]string, 0, len(all))
+
+	h := sha1.New()
+	for _, x := range all {
+		sha, err := git.RevParseHead(x)
+		if err != nil {
+			errs = append(errs, err.Error())
+			continue
+		}
+		fmt.Fprint(h, sha)
+		changed, err := hashChanges(h, x)
+		if err != nil {
+			errs = append(errs, err.Error())
+			continue
+		}
+		if changed {
+			dirty = append(dirty, NewRepository(wk.wd, x).Rel)
+		}
+	}
+	if len(errs) > 0 {
+		return nil, dirty, errors.New(strings.Join(errs, "\n"))
+	}
+	return h.Sum(nil), dirty, nil
+}
+
+//DeclaredVersion computes the workspace version from the '.sbr' declarations only: the sha1 of the top repository HEAD,
+// followed by the path, the declared remote and the HEAD of each declared subrepository, in path order. Other repositories
+// in the workspace are ignored. Its text form starts with VersionPrefix.
+//
+// With 'worktree', local changes are also hashed, as in WorktreeVersion, and repositories with local changes are returned.
+func (wk *Workspace) DeclaredVersion(worktree bool) (version []byte, dirty []string, err error) {
+	subs, err := wk.Read()
+	if err != nil {
+		return nil, nil, err
+	}
+	sort.Sort(byRel(subs)) // not by branch, it is not part of the version
+	errs := make([]string, 0, len(subs))
+
+	h := sha1.New()
+	hash := func(rel, remote string) {
+		prj := filepath.Join(wk.wd, rel)
+		sha, err := git.RevParseHead(prj)
+		if err != nil {
+			errs = append(errs, err.Error())
+			return
+		}
+		fmt.Fprintf(h, "%q %q %q\n", rel, remote, sha)
+		if !worktree {
+			return
+		}
+		changed, err := hashChanges(h, prj)
+		if err != nil {
+			errs = append(errs, err.Error())
+			return
+		}
+		if changed {
+			dirty = append(dirty, rel)
+		}
+	}
+	hash(".", "")
+	for _, s := range subs {
+		hash(s.rel, s.remote)
+	}
+	if len(errs) > 0 {
+		return nil, dirty, errors.New(strings.Join(errs, "\n"))
+	}
+	return h.Sum(nil), dirty, nil
+}
+
+//HasLocalChanges returns true if 'prj' has local changes: changes to tracked files, or untracked files. Nested
+// repositories are not changes of 'prj'.
+func HasLocalChanges(prj string) (changed bool, err error) { return hashChanges(ioutil.Discard, prj) }
+
+//hashChanges writes the local changes of 'prj' to 'w', if any: the current content of changed tracked files, and of
+// untracked files. Only the content is hashed (not a diff), so that it does not depend on the git configuration.
+//
+// untracked directories are nested repositories, they are not part of 'prj' changes.
+func hashChanges(w io.Writer, prj string) (changed bool, err error) {
+	tracked, err := git.Changed(prj)
+	if err != nil {
+		return false, err
+	}
+	untracked, err := git.Untracked(prj)
+	if err != nil {
+		return false, err
+	}
+	for _, f := range tracked {
+		if err = hashFile(w, prj, "M", f); err != nil {
+			return true, err
+		}
+		changed = true
+	}
+	for _, f := range untracked {
+		if strings.HasSuffix(f, "/") {
+			continue
+		}
+		if err = hashFile(w, prj, "?", f); err != nil {
+			return true, err
+		}
+		changed = true
+	}
+	return changed, nil
+}
+
+//hashFile writes the state of the file 'name' in 'prj' to 'w': deleted, a symlink and its target, a submodule and
+// its HEAD, or a file (executable or not) and its content.
+func hashFile(w io.Writer, prj, status, name string) error {
+	p := filepath.Join(prj, filepath.FromSlash(name))
+	info, err := os.Lstat(p)
+	var kind string
+	var content []byte
+	switch {
+	case os.IsNotExist(err):
+		kind = "deleted"
+	case err != nil:
+		return err
+	case info.Mode()&os.ModeSymlink != 0:
+		kind = "symlink"
+		target, err := os.Readlink(p)
+		if err != nil {
+			return err
+		}
+		content = []byte(target)
+	case info.IsDir():
+		kind = "submodule"
+		sha, err := git.RevParseHead(p)
+		if err != nil {
+			return err
+		}
+		content = []byte(sha)
+	default:
+		kind = "file"
+		if info.Mode()&0111 != 0 {
+			kind = "executable"
+		}
+		if content, err = ioutil.ReadFile(p); err != nil {
+			return err
+		}
+	}
+	fmt.Fprintf(w, "%s\x00%s\x00%s\x00%d\x00", status, name, kind, len(content))
+	w.Write(content)
+	return nil
+}
+
+type byRel []Sub
+
+func (a byRel) Len() int           { return len(a) }
+func (a byRel) Swap(i, j int)      { a[i], a[j] = a[j], a[i] }
+func (a byRel) Less(i, j int) bool { return a[i].rel < a[j].rel }
+
 //fileExists check if a path exists
 func fileExists(path string) bool {
 	_, err := os.Stat(path)